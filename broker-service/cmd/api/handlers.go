@@ -1,26 +1,34 @@
 package main
 
 import (
-	"broker/event"
 	"broker/logs"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/grpc/metadata"
 	"log"
 	"net/http"
 	"net/rpc"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type RequestPayload struct {
-	Action string      `json:"action"`
-	Auth   AuthPayload `json:"auth,omitempty"`
-	Log    LogPayload  `json:"log,omitempty"`
-	Mail   MailPayload `json:"mail,omitempty"`
+	Action string `json:"action"`
+	// Transport picks how a log action reaches the logger: rpc, grpc, http
+	// or rabbit. It defaults to LOG_TRANSPORT.
+	Transport string       `json:"transport,omitempty"`
+	Auth      AuthPayload  `json:"auth,omitempty"`
+	Log       LogPayload   `json:"log,omitempty"`
+	Logs      []LogPayload `json:"logs,omitempty"`
+	Mail      MailPayload  `json:"mail,omitempty"`
+	Stats     StatsPayload `json:"stats,omitempty"`
 }
 
 type MailPayload struct {
@@ -36,13 +44,26 @@ type AuthPayload struct {
 }
 
 type LogPayload struct {
-	Name string `json:"name"`
-	Data string `json:"data"`
+	Name  string `json:"name"`
+	Data  string `json:"data"`
+	Level string `json:"level,omitempty"`
 }
 
 type RPCPayload struct {
-	Name string `json:"name"`
-	Data string `json:"data"`
+	Name     string    `json:"name"`
+	Data     string    `json:"data"`
+	Level    string    `json:"level"`
+	Tenant   string    `json:"tenant"`
+	Deadline time.Time `json:"deadline"`
+}
+
+type StatsPayload struct {
+	Bucket string `json:"bucket,omitempty"`
+	Top    int    `json:"top,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Level  string `json:"level,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
 }
 
 func (app *Config) Broker(w http.ResponseWriter, r *http.Request) {
@@ -73,11 +94,14 @@ func (app *Config) HandleSubmission(w http.ResponseWriter, r *http.Request) {
 		app.authenticate(w, requestPayload.Auth)
 
 	case "log":
-		app.LogItemViaRPC(w, requestPayload.Log)
+		app.logEntry(w, requestPayload.Log, requestPayload.Transport, r.Header.Get(tenantHeader))
 
 	case "mail":
 		app.sendMail(w, requestPayload.Mail)
 
+	case "stats":
+		app.logStats(w, requestPayload.Stats, r.Header.Get(tenantHeader))
+
 	default:
 		app.errorJSON(w, errors.New("invalid action"))
 	}
@@ -171,64 +195,117 @@ func (app *Config) sendMail(w http.ResponseWriter, m MailPayload) {
 
 }
 
-func (app *Config) logItem(w http.ResponseWriter, l LogPayload) {
+// logViaHTTP posts the entry to the logger's /log endpoint.
+func (app *Config) logViaHTTP(l LogPayload, tenant string) (string, error) {
 	jsonData, _ := json.MarshalIndent(l, "", "\t")
 	request, err := http.NewRequest("POST", "http://logger-service/log", bytes.NewBuffer(jsonData))
 	if err != nil {
-		app.errorJSON(w, errors.New("error creating request"))
-		return
+		return "", errors.New("error creating request")
 	}
 
 	request.Header.Set("Content-Type", "application/json")
+	setTenant(request, tenant)
 	client := &http.Client{}
 	response, err := client.Do(request)
 	if err != nil {
-		app.errorJSON(w, err)
-		return
+		return "", err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusAccepted {
-		app.errorJSON(w, errors.New("error calling logger service"))
-		return
+		return "", errors.New("error calling logger service")
 	}
 
-	var payload jsonResponse
-	payload.Error = false
-	payload.Message = "Logged successfully!"
+	return "Logged successfully!", nil
+}
 
-	app.writeJSON(w, http.StatusAccepted, payload)
+// logStats fetches aggregated log counts from the logger's /logs/stats.
+func (app *Config) logStats(w http.ResponseWriter, s StatsPayload, tenant string) {
+	params := url.Values{}
+	if s.Bucket != "" {
+		params.Set("bucket", s.Bucket)
+	}
+	if s.Top > 0 {
+		params.Set("top", strconv.Itoa(s.Top))
+	}
+	if s.Name != "" {
+		params.Set("name", s.Name)
+	}
+	if s.Level != "" {
+		params.Set("level", s.Level)
+	}
+	if s.From != "" {
+		params.Set("from", s.From)
+	}
+	if s.To != "" {
+		params.Set("to", s.To)
+	}
 
-}
+	request, err := http.NewRequest("GET", "http://logger-service/logs/stats?"+params.Encode(), nil)
+	if err != nil {
+		app.errorJSON(w, errors.New("error creating request"))
+		return
+	}
+	setTenant(request, tenant)
 
-func (app *Config) logEventViaRabbit(w http.ResponseWriter, l LogPayload) {
-	err := app.pushToQueue(l.Name, l.Data)
+	client := &http.Client{}
+	response, err := client.Do(request)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	defer response.Body.Close()
+
+	var jsonFromService jsonResponse
+
+	err = json.NewDecoder(response.Body).Decode(&jsonFromService)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
 
+	if response.StatusCode != http.StatusOK || jsonFromService.Error {
+		app.errorJSON(w, errors.New("error calling logger service: "+jsonFromService.Message))
+		return
+	}
+
 	var payload jsonResponse
 	payload.Error = false
-	payload.Message = "Logged successfully! via RabbitMQ"
+	payload.Message = "Log stats"
+	payload.Data = jsonFromService.Data
 
-	app.writeJSON(w, http.StatusAccepted, payload)
+	app.writeJSON(w, http.StatusOK, payload)
 }
 
-func (app *Config) pushToQueue(name, msg string) error {
-	emitter, err := event.NewEventEmitter(app.Rabbit)
+func (app *Config) logViaRabbit(l LogPayload, tenant string) (string, error) {
+	err := app.pushToQueue(l.Name, l.Data, l.Level, tenant)
 	if err != nil {
-		log.Println("error creating emitter", err)
-		return err
+		return "", err
+	}
+
+	return "Logged successfully! via RabbitMQ", nil
+}
+
+// pushToQueue publishes the entry on logs_topic; the tenant, if any, travels
+// in the x-tenant-id message header.
+func (app *Config) pushToQueue(name, msg, level, tenant string) error {
+	if level == "" {
+		level = "INFO"
 	}
 
 	payload := LogPayload{
-		Name: name,
-		Data: msg,
+		Name:  name,
+		Data:  msg,
+		Level: strings.ToUpper(level),
 	}
 
 	j, _ := json.Marshal(payload)
-	err = emitter.Push(string(j), "log.INFO")
+	var headers amqp.Table
+	if tenant != "" {
+		headers = amqp.Table{tenantMetadataKey: tenant}
+	}
+
+	err := app.Emitter.Push(string(j), "log."+payload.Level, headers)
 	if err != nil {
 		log.Println("error pushing to queue", err)
 		return err
@@ -236,32 +313,42 @@ func (app *Config) pushToQueue(name, msg string) error {
 	return nil
 }
 
-func (app *Config) LogItemViaRPC(w http.ResponseWriter, l LogPayload) {
-	client, err := rpc.Dial("tcp", "logger-service:5001")
-	if err != nil {
-		log.Println("Error dialing RPC server", err)
-		app.errorJSON(w, err)
-		return
-	}
-	defer client.Close()
+// loggerRPCTimeout bounds a net/rpc call to the logger.
+const loggerRPCTimeout = 5 * time.Second
 
+func (app *Config) logViaRPC(l LogPayload, tenant string) (string, error) {
+	deadline := time.Now().Add(loggerRPCTimeout)
 	rpcPayload := RPCPayload{
-		Name: l.Name,
-		Data: l.Data,
+		Name:     l.Name,
+		Data:     l.Data,
+		Level:    l.Level,
+		Tenant:   tenant,
+		Deadline: deadline,
 	}
 
 	var result string
-	err = client.Call("RPCServer.LogInfo", rpcPayload, &result)
+	err := app.LoggerRPC.call("RPCServer.LogInfo", rpcPayload, &result, deadline)
 	if err != nil {
 		log.Println("Error calling RPC server", err)
-		app.errorJSON(w, err)
-		return
+		return "", err
 	}
 
-	var payload jsonResponse
-	payload.Error = false
-	payload.Message = result
-	app.writeJSON(w, http.StatusAccepted, payload)
+	return result, nil
+}
+
+// callWithDeadline calls method and stops waiting for the reply at
+// deadline, which the logger is also given in the payload.
+func callWithDeadline(client *rpc.Client, method string, args any, reply any, deadline time.Time) error {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-timer.C:
+		return fmt.Errorf("%s: %w", method, context.DeadlineExceeded)
+	}
 }
 
 func (app *Config) LogVIAgRPC(w http.ResponseWriter, r *http.Request) {
@@ -273,28 +360,98 @@ func (app *Config) LogVIAgRPC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := grpc.NewClient("logger-service:50001", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	entries := resquestPayload.Logs
+	if len(entries) == 0 {
+		entries = []LogPayload{resquestPayload.Log}
+	}
+
+	if len(entries) == 1 {
+		app.logEntry(w, entries[0], transportGRPC, r.Header.Get(tenantHeader))
+		return
+	}
+
+	c, err := app.LoggerGRPC.client()
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
-	defer conn.Close()
 
-	c := logs.NewLoggerClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := loggerGRPCContext(r.Header.Get(tenantHeader))
+	defer cancel()
+
+	app.logBatchViagRPC(ctx, w, c, entries)
+}
+
+func (app *Config) logViaGRPC(l LogPayload, tenant string) (string, error) {
+	c, err := app.LoggerGRPC.client()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := loggerGRPCContext(tenant)
 	defer cancel()
 
 	_, err = c.WriteLog(ctx, &logs.LogRequest{
 		LogEntry: &logs.Log{
-			Name: resquestPayload.Log.Name,
-			Data: resquestPayload.Log.Data,
+			Name:  l.Name,
+			Data:  l.Data,
+			Level: l.Level,
 		},
 	})
+	if err != nil {
+		return "", err
+	}
+
+	return "Logged successfully! via gRPC", nil
+}
+
+// loggerGRPCContext bounds a gRPC call to the logger and carries the tenant
+// and the LOGGER_GRPC_TOKEN bearer token as metadata.
+func loggerGRPCContext(tenant string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	if tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenantMetadataKey, tenant)
+	}
+	if token := os.Getenv("LOGGER_GRPC_TOKEN"); token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	return ctx, cancel
+}
+
+// logBatchViagRPC streams several entries to the logger over a single
+// WriteLogs call so they are stored with one InsertMany.
+func (app *Config) logBatchViagRPC(ctx context.Context, w http.ResponseWriter, c logs.LoggerClient, entries []LogPayload) {
+	stream, err := c.WriteLogs(ctx)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	for _, entry := range entries {
+		err = stream.Send(&logs.LogRequest{
+			LogEntry: &logs.Log{
+				Name:  entry.Name,
+				Data:  entry.Data,
+				Level: entry.Level,
+			},
+		})
+		if err != nil {
+			log.Println("Error streaming log entry", err)
+			app.errorJSON(w, err)
+			return
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
 
 	var payload jsonResponse
-	payload.Error = false
-	payload.Message = "Logged successfully! via gRPC"
+	payload.Error = res.GetFailed() > 0
+	payload.Message = res.GetMessage() + " via gRPC"
+	payload.Data = res.GetResults()
 
 	app.writeJSON(w, http.StatusAccepted, payload)
-
 }
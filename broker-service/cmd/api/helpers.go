@@ -8,6 +8,20 @@ import (
 	"net/http"
 )
 
+// tenantHeader carries the tenant id of log writes and queries. It is
+// forwarded to the logger as the same HTTP header, as gRPC metadata under
+// tenantMetadataKey, in the RPC payload and as an AMQP message header.
+const tenantHeader = "X-Tenant-ID"
+
+const tenantMetadataKey = "x-tenant-id"
+
+// setTenant forwards tenant on a request to the logger.
+func setTenant(request *http.Request, tenant string) {
+	if tenant != "" {
+		request.Header.Set(tenantHeader, tenant)
+	}
+}
+
 type jsonResponse struct {
 	Error   bool   `json:"error"`
 	Message string `json:"msg"`
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"broker/logs"
+	"errors"
+	"expvar"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"log"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+var (
+	rpcPoolOpen    = expvar.NewInt("logger_rpc_pool_open")
+	rpcPoolStats   = expvar.NewMap("logger_rpc_pool")
+	grpcConnStats  = expvar.NewMap("logger_grpc_conn")
+	grpcConnHealth = expvar.NewString("logger_grpc_health")
+)
+
+// rpcPool keeps up to size net/rpc clients to the logger, dialed on first
+// use and used round robin. A client whose connection fails is discarded and
+// redialed by the next call that lands on its slot.
+type rpcPool struct {
+	dial func() (*rpc.Client, error)
+
+	mu      sync.Mutex
+	clients []*rpc.Client
+	next    int
+}
+
+func newRPCPool(size int, dial func() (*rpc.Client, error)) *rpcPool {
+	if size <= 0 {
+		size = 4
+	}
+	return &rpcPool{
+		dial:    dial,
+		clients: make([]*rpc.Client, size),
+	}
+}
+
+// get returns the client of the next slot, dialing it if needed. The dial
+// runs without the lock so a slow logger only holds up the calls on that
+// slot.
+func (p *rpcPool) get() (int, *rpc.Client, error) {
+	p.mu.Lock()
+	slot := p.next
+	p.next = (p.next + 1) % len(p.clients)
+	client := p.clients[slot]
+	p.mu.Unlock()
+
+	if client != nil {
+		return slot, client, nil
+	}
+
+	client, err := p.dial()
+	rpcPoolStats.Add("dials", 1)
+	if err != nil {
+		rpcPoolStats.Add("dial_errors", 1)
+		return slot, nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Another call may have filled the slot while this one was dialing.
+	if existing := p.clients[slot]; existing != nil {
+		client.Close()
+		return slot, existing, nil
+	}
+	p.clients[slot] = client
+	rpcPoolOpen.Add(1)
+
+	return slot, client, nil
+}
+
+// discard closes client and frees its slot, unless it was replaced already.
+func (p *rpcPool) discard(slot int, client *rpc.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clients[slot] != client {
+		return
+	}
+	p.clients[slot] = nil
+	client.Close()
+	rpcPoolOpen.Add(-1)
+	rpcPoolStats.Add("discarded", 1)
+}
+
+// call runs method on a pooled client. Errors returned by the logger leave
+// the connection in the pool; any other failure discards it.
+func (p *rpcPool) call(method string, args any, reply any, deadline time.Time) error {
+	slot, client, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	err = callWithDeadline(client, method, args, reply, deadline)
+	var serverErr rpc.ServerError
+	if err != nil && !errors.As(err, &serverErr) {
+		p.discard(slot, client)
+	}
+	return err
+}
+
+// check pings every open client and discards those that do not answer.
+func (p *rpcPool) check() {
+	p.mu.Lock()
+	clients := make([]*rpc.Client, len(p.clients))
+	copy(clients, p.clients)
+	p.mu.Unlock()
+
+	for slot, client := range clients {
+		if client == nil {
+			continue
+		}
+		var reply string
+		err := callWithDeadline(client, "RPCServer.Ping", "", &reply, time.Now().Add(loggerRPCTimeout))
+		if err != nil {
+			log.Println("Discarding logger RPC connection", err)
+			rpcPoolStats.Add("failed_checks", 1)
+			p.discard(slot, client)
+		}
+	}
+}
+
+func (p *rpcPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for slot, client := range p.clients {
+		if client != nil {
+			client.Close()
+			p.clients[slot] = nil
+			rpcPoolOpen.Add(-1)
+		}
+	}
+}
+
+// grpcConn holds one lazily created gRPC connection to the logger. HTTP/2
+// multiplexes every call over it and gRPC reconnects it on its own; it is
+// only recreated after being shut down.
+type grpcConn struct {
+	dial func() (*grpc.ClientConn, error)
+
+	mu   sync.Mutex
+	conn *grpc.ClientConn
+}
+
+func newGRPCConn(dial func() (*grpc.ClientConn, error)) *grpcConn {
+	return &grpcConn{dial: dial}
+}
+
+func (g *grpcConn) get() (*grpc.ClientConn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn != nil && g.conn.GetState() != connectivity.Shutdown {
+		return g.conn, nil
+	}
+
+	conn, err := g.dial()
+	grpcConnStats.Add("dials", 1)
+	if err != nil {
+		grpcConnStats.Add("dial_errors", 1)
+		return nil, err
+	}
+	g.conn = conn
+
+	return conn, nil
+}
+
+func (g *grpcConn) client() (logs.LoggerClient, error) {
+	conn, err := g.get()
+	if err != nil {
+		return nil, err
+	}
+	return logs.NewLoggerClient(conn), nil
+}
+
+// state reports the connectivity state for /debug/vars.
+func (g *grpcConn) state() any {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn == nil {
+		return "NOT_CONNECTED"
+	}
+	return g.conn.GetState().String()
+}
+
+// check asks the logger's health service whether it is serving. A failed
+// check skips the reconnect backoff so the next call dials right away.
+func (g *grpcConn) check() {
+	g.mu.Lock()
+	conn := g.conn
+	g.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+
+	ctx, cancel := loggerGRPCContext("")
+	defer cancel()
+
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "logs.Logger"})
+	if err != nil {
+		grpcConnStats.Add("failed_checks", 1)
+		grpcConnHealth.Set("UNKNOWN")
+		log.Println("Logger gRPC health check failed", err)
+		conn.ResetConnectBackoff()
+		return
+	}
+	grpcConnHealth.Set(res.GetStatus().String())
+}
+
+func (g *grpcConn) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn != nil {
+		g.conn.Close()
+	}
+}
+
+// checkLoggerConns health checks the pooled logger connections every
+// interval.
+func (app *Config) checkLoggerConns(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.LoggerRPC.check()
+		app.LoggerGRPC.check()
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"expvar"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -15,7 +16,7 @@ func (app *Config) routes() http.Handler {
 	mux.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", tenantHeader},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -27,5 +28,7 @@ func (app *Config) routes() http.Handler {
 	mux.Post("/handle", app.HandleSubmission)
 	mux.Post("/log-grpc", app.LogVIAgRPC)
 
+	mux.Handle("/debug/vars", expvar.Handler())
+
 	return mux
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"log"
+	"net"
+	"net/rpc"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	loggerRPCAddr  = "logger-service:5001"
+	loggerGRPCAddr = "logger-service:50001"
+	// loggerDialTimeout bounds connecting to the logger's net/rpc listener,
+	// TLS handshake included.
+	loggerDialTimeout = 5 * time.Second
+)
+
+// certCheckInterval is how often a dial may look at the certificate files
+// for changes.
+const certCheckInterval = 10 * time.Second
+
+var errPlaintext = errors.New("LOGGER_TLS_CA is not set; set LOGGER_TLS_INSECURE=true to talk to the logger in plaintext")
+
+// loggerTLS holds the client side of TLS to the logger's RPC and gRPC
+// listeners and reloads the files when they change.
+type loggerTLS struct {
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+
+	mu        sync.Mutex
+	config    *tls.Config
+	modified  time.Time
+	lastCheck time.Time
+}
+
+// newLoggerTLS reads LOGGER_TLS_CA, the CA the logger's certificate is
+// checked against, and for mutual TLS LOGGER_TLS_CERT and LOGGER_TLS_KEY.
+// LOGGER_TLS_SERVER_NAME overrides the name expected in the certificate. It
+// returns nil only when plaintext was asked for with LOGGER_TLS_INSECURE.
+func newLoggerTLS() (*loggerTLS, error) {
+	caFile := os.Getenv("LOGGER_TLS_CA")
+	if caFile == "" {
+		if insecure, _ := strconv.ParseBool(os.Getenv("LOGGER_TLS_INSECURE")); insecure {
+			return nil, nil
+		}
+		return nil, errPlaintext
+	}
+
+	serverName := os.Getenv("LOGGER_TLS_SERVER_NAME")
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(loggerRPCAddr)
+	}
+
+	t := &loggerTLS{
+		caFile:     caFile,
+		certFile:   os.Getenv("LOGGER_TLS_CERT"),
+		keyFile:    os.Getenv("LOGGER_TLS_KEY"),
+		serverName: serverName,
+	}
+	if (t.certFile == "") != (t.keyFile == "") {
+		return nil, errors.New("LOGGER_TLS_CERT and LOGGER_TLS_KEY must be set together")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastCheck = time.Now()
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// current returns the config for a new connection, reloading the files
+// first when they changed.
+func (t *loggerTLS) current() *tls.Config {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.lastCheck) >= certCheckInterval {
+		t.lastCheck = time.Now()
+		if t.changed() {
+			if err := t.reload(); err != nil {
+				// Keep using the certificates we have.
+				log.Println("Error reloading TLS certificates", err)
+			} else {
+				log.Println("Reloaded TLS certificates from", t.caFile)
+			}
+		}
+	}
+
+	return t.config.Clone()
+}
+
+// changed reports whether any of the files is newer than what was loaded.
+// Callers hold t.mu.
+func (t *loggerTLS) changed() bool {
+	for _, name := range []string{t.caFile, t.certFile, t.keyFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err == nil && info.ModTime().After(t.modified) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload reads the files and swaps in a new config. Callers hold t.mu.
+func (t *loggerTLS) reload() error {
+	modified := time.Now()
+
+	pem, err := os.ReadFile(t.caFile)
+	if err != nil {
+		return fmt.Errorf("loading logger CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates in %s", t.caFile)
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		ServerName: t.serverName,
+	}
+
+	if t.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	t.config = config
+	t.modified = modified
+	return nil
+}
+
+// dialLoggerRPC connects to the logger's net/rpc listener.
+func (app *Config) dialLoggerRPC() (*rpc.Client, error) {
+	dialer := &net.Dialer{Timeout: loggerDialTimeout}
+
+	if app.LoggerTLS == nil {
+		conn, err := dialer.Dial("tcp", loggerRPCAddr)
+		if err != nil {
+			return nil, err
+		}
+		return rpc.NewClient(conn), nil
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", loggerRPCAddr, app.LoggerTLS.current())
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewClient(conn), nil
+}
+
+// dialLoggerGRPC creates a gRPC connection to the logger. It connects on
+// first use.
+func (app *Config) dialLoggerGRPC() (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if app.LoggerTLS != nil {
+		creds = credentials.NewTLS(app.LoggerTLS.current())
+	}
+	return grpc.NewClient(loggerGRPCAddr, grpc.WithTransportCredentials(creds))
+}
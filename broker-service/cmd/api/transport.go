@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	transportRPC    = "rpc"
+	transportGRPC   = "grpc"
+	transportHTTP   = "http"
+	transportRabbit = "rabbit"
+)
+
+// logTransports lists the ways to reach the logger in the default fallback
+// order.
+var logTransports = []string{transportRPC, transportGRPC, transportHTTP, transportRabbit}
+
+// LogResult tells the client which transport stored the entry.
+type LogResult struct {
+	Transport string `json:"transport"`
+	// Failed lists the transports tried before it.
+	Failed []TransportError `json:"failed,omitempty"`
+}
+
+type TransportError struct {
+	Transport string `json:"transport"`
+	Error     string `json:"error"`
+}
+
+// readLogTransports reads LOG_TRANSPORT, the transport used when a request
+// names none (rpc by default), and LOG_TRANSPORT_FALLBACK, the comma
+// separated transports tried in order when it fails. The fallback defaults
+// to every other transport; "none" turns it off.
+func readLogTransports() (string, []string, error) {
+	transport := os.Getenv("LOG_TRANSPORT")
+	if transport == "" {
+		transport = transportRPC
+	}
+	if !slices.Contains(logTransports, transport) {
+		return "", nil, fmt.Errorf("unknown LOG_TRANSPORT %q", transport)
+	}
+
+	fallback := logTransports
+	switch value := os.Getenv("LOG_TRANSPORT_FALLBACK"); value {
+	case "":
+	case "none":
+		fallback = nil
+	default:
+		fallback = nil
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if !slices.Contains(logTransports, name) {
+				return "", nil, fmt.Errorf("unknown transport %q in LOG_TRANSPORT_FALLBACK", name)
+			}
+			fallback = append(fallback, name)
+		}
+	}
+
+	return transport, fallback, nil
+}
+
+func (app *Config) logVia(transport string, l LogPayload, tenant string) (string, error) {
+	switch transport {
+	case transportRPC:
+		return app.logViaRPC(l, tenant)
+	case transportGRPC:
+		return app.logViaGRPC(l, tenant)
+	case transportHTTP:
+		return app.logViaHTTP(l, tenant)
+	default:
+		return app.logViaRabbit(l, tenant)
+	}
+}
+
+// logEntry sends l over transport, or the default one, and then over the
+// fallback transports until one succeeds. A transport that timed out may
+// still have stored the entry, so a fallback can log it twice.
+func (app *Config) logEntry(w http.ResponseWriter, l LogPayload, transport, tenant string) {
+	if transport == "" {
+		transport = app.LogTransport
+	}
+	if !slices.Contains(logTransports, transport) {
+		app.errorJSON(w, fmt.Errorf("unknown transport %q; use one of %s", transport, strings.Join(logTransports, ", ")))
+		return
+	}
+
+	order := []string{transport}
+	for _, name := range app.LogFallback {
+		if name != transport {
+			order = append(order, name)
+		}
+	}
+
+	var result LogResult
+	for _, name := range order {
+		message, err := app.logVia(name, l, tenant)
+		if err != nil {
+			log.Println("Error logging via", name, err)
+			result.Failed = append(result.Failed, TransportError{Transport: name, Error: err.Error()})
+			continue
+		}
+
+		result.Transport = name
+		app.writeJSON(w, http.StatusAccepted, jsonResponse{
+			Error:   false,
+			Message: message,
+			Data:    result,
+		})
+		return
+	}
+
+	app.writeJSON(w, http.StatusBadGateway, jsonResponse{
+		Error:   true,
+		Message: "error calling logger service",
+		Data:    result,
+	})
+}
@@ -0,0 +1,212 @@
+package event
+
+import (
+	"context"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDisconnected is returned while RabbitMQ is being redialed.
+	ErrDisconnected = errors.New("not connected to rabbitmq")
+	ErrClosed       = errors.New("rabbitmq connection closed")
+)
+
+// maxRedialBackoff caps the wait between attempts to reconnect.
+const maxRedialBackoff = 30 * time.Second
+
+// Connection is a connection to RabbitMQ that is redialed whenever it is
+// lost. Channels do not survive a reconnect; their users open new ones.
+type Connection struct {
+	url string
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	ready     chan struct{} // closed while conn is usable
+	onConnect []func(*amqp.Connection) error
+	closed    bool
+	done      chan struct{}
+}
+
+// Connect dials url, retrying a few times with a growing backoff, and keeps
+// the connection up from then on.
+func Connect(url string) (*Connection, error) {
+	var counts int64
+	var conn *amqp.Connection
+
+	for {
+		var err error
+		conn, err = amqp.Dial(url)
+		if err == nil {
+			log.Println("connected to rabbitmq")
+			break
+		}
+		log.Println("error connecting to rabbitmq", err)
+		counts++
+		if counts > 5 {
+			log.Println("error connecting to rabbitmq after 5 attempts")
+			return nil, err
+		}
+		backOff := time.Duration(math.Pow(float64(counts), 2)) * time.Second
+		log.Println("Backing off")
+		time.Sleep(backOff)
+	}
+
+	c := &Connection{
+		url:   url,
+		conn:  conn,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	close(c.ready)
+
+	go c.watch(conn)
+
+	return c, nil
+}
+
+// OnConnect runs setup now and again after every reconnect, before the new
+// connection is handed out. Use it to redeclare exchanges.
+func (c *Connection) OnConnect(setup func(*amqp.Connection) error) error {
+	c.mu.Lock()
+	c.onConnect = append(c.onConnect, setup)
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return setup(conn)
+}
+
+// Current returns the connection, or ErrDisconnected without waiting while
+// it is down.
+func (c *Connection) Current() (*amqp.Connection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+	if c.conn == nil {
+		return nil, ErrDisconnected
+	}
+	return c.conn, nil
+}
+
+// Wait returns the connection once it is up.
+func (c *Connection) Wait(ctx context.Context) (*amqp.Connection, error) {
+	for {
+		c.mu.Lock()
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+
+		if conn != nil {
+			return conn, nil
+		}
+
+		select {
+		case <-ready:
+		case <-c.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Channel opens a channel on the current connection.
+func (c *Connection) Channel() (*amqp.Channel, error) {
+	conn, err := c.Current()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Channel()
+}
+
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// watch redials whenever conn is lost, until Close is called.
+func (c *Connection) watch(conn *amqp.Connection) {
+	for {
+		err := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return
+		}
+		c.conn = nil
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+
+		log.Println("lost connection to rabbitmq", err)
+
+		conn = c.redial()
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// redial dials until it succeeds, runs the OnConnect setups and hands the
+// connection out. It returns nil if Close is called meanwhile.
+func (c *Connection) redial() *amqp.Connection {
+	backOff := time.Second
+	for {
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(backOff):
+		}
+
+		conn, err := amqp.Dial(c.url)
+		if err != nil {
+			log.Println("error reconnecting to rabbitmq", err)
+			backOff = min(2*backOff, maxRedialBackoff)
+			continue
+		}
+
+		c.mu.Lock()
+		setups := c.onConnect
+		c.mu.Unlock()
+		for _, setup := range setups {
+			if err := setup(conn); err != nil {
+				log.Println("error setting up rabbitmq connection", err)
+			}
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		c.conn = conn
+		close(c.ready)
+		c.mu.Unlock()
+
+		log.Println("reconnected to rabbitmq")
+		return conn
+	}
+}
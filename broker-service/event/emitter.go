@@ -1,16 +1,51 @@
 package event
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"log"
+	"time"
 )
 
+var (
+	// ErrNacked means RabbitMQ refused the message or lost the channel
+	// before confirming it.
+	ErrNacked = errors.New("message was not acknowledged by rabbitmq")
+	// ErrUnroutable means no queue is bound for the routing key.
+	ErrUnroutable = errors.New("message could not be routed to a queue")
+	// ErrConfirmTimeout means RabbitMQ did not confirm the message in time.
+	// It may still have stored it.
+	ErrConfirmTimeout = errors.New("timed out waiting for rabbitmq to confirm the message")
+)
+
+const (
+	defaultChannelPoolSize = 4
+	defaultConfirmTimeout  = 5 * time.Second
+)
+
+// confirmChannel is a channel in confirm mode together with the messages
+// RabbitMQ returned on it.
+type confirmChannel struct {
+	*amqp.Channel
+	returns chan amqp.Return
+}
+
+// Emitter publishes on logs_topic over a pool of channels in confirm mode.
+// A channel is used by one Push at a time, so a returned message always
+// belongs to the Push holding the channel. While RabbitMQ is unreachable
+// Push fails fast with ErrDisconnected instead of buffering, leaving the
+// caller free to log some other way.
 type Emitter struct {
-	connection *amqp.Connection
+	connection     *Connection
+	channels       chan *confirmChannel
+	confirmTimeout time.Duration
 }
 
-func (e *Emitter) setup() error {
-	channel, err := e.connection.Channel()
+// setup declares logs_topic on conn. It runs again after every reconnect.
+func (e *Emitter) setup(conn *amqp.Connection) error {
+	channel, err := conn.Channel()
 	if err != nil {
 		return err
 	}
@@ -18,41 +53,136 @@ func (e *Emitter) setup() error {
 	return declareExchange(channel)
 }
 
-func (e *Emitter) Push(event string, severity string) error {
+func (e *Emitter) open() (*confirmChannel, error) {
 	channel, err := e.connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, err
+	}
+
+	return &confirmChannel{
+		Channel: channel,
+		returns: channel.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+// get takes an idle channel from the pool or opens a new one. Channels of a
+// lost connection are closed and get dropped here.
+func (e *Emitter) get() (*confirmChannel, error) {
+	for {
+		select {
+		case channel := <-e.channels:
+			if !channel.IsClosed() {
+				return channel, nil
+			}
+		default:
+			return e.open()
+		}
+	}
+}
+
+// put returns channel to the pool, closing it when the pool is full.
+func (e *Emitter) put(channel *confirmChannel) {
+	select {
+	case e.channels <- channel:
+	default:
+		channel.Close()
+	}
+}
+
+// Push publishes event on logs_topic with severity as the routing key, as a
+// persistent and mandatory message. It returns once RabbitMQ has confirmed
+// it, and fails if RabbitMQ nacks or returns it or does not answer within
+// the confirm timeout. headers may be nil.
+func (e *Emitter) Push(event string, severity string, headers amqp.Table) error {
+	channel, err := e.get()
 	if err != nil {
 		return err
 	}
-	defer channel.Close()
 
-	log.Println("pushing to channel")
+	ctx, cancel := context.WithTimeout(context.Background(), e.confirmTimeout)
+	defer cancel()
 
-	err = channel.Publish(
+	confirm, err := channel.PublishWithDeferredConfirmWithContext(
+		ctx,
 		"logs_topic",
 		severity,
-		false,
+		true,
 		false,
 		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(event),
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    time.Now(),
+			Headers:      headers,
+			Body:         []byte(event),
 		},
 	)
-
 	if err != nil {
 		log.Println("error publishing message", err)
+		channel.Close()
 		return err
 	}
 
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		// A late confirm would be taken for the next message's, so the
+		// channel cannot be reused.
+		channel.Close()
+		return ErrConfirmTimeout
+	}
+	if !acked {
+		channel.Close()
+		return ErrNacked
+	}
+
+	// RabbitMQ sends basic.return before the ack, so a returned message is
+	// already waiting.
+	select {
+	case ret := <-channel.returns:
+		e.put(channel)
+		log.Println("message returned by rabbitmq", ret.ReplyCode, ret.ReplyText, ret.RoutingKey)
+		return fmt.Errorf("%w: %s (routing key %s)", ErrUnroutable, ret.ReplyText, ret.RoutingKey)
+	default:
+	}
+
+	e.put(channel)
 	return nil
 }
 
-func NewEventEmitter(conn *amqp.Connection) (Emitter, error) {
-	emitter := Emitter{
-		connection: conn,
+// Close closes the idle channels.
+func (e *Emitter) Close() {
+	for {
+		select {
+		case channel := <-e.channels:
+			channel.Close()
+		default:
+			return
+		}
+	}
+}
+
+// NewEventEmitter declares logs_topic and returns an Emitter keeping up to
+// poolSize idle channels and waiting up to confirmTimeout for each confirm.
+// Zero values pick the defaults.
+func NewEventEmitter(conn *Connection, poolSize int, confirmTimeout time.Duration) (*Emitter, error) {
+	if poolSize <= 0 {
+		poolSize = defaultChannelPoolSize
+	}
+	if confirmTimeout <= 0 {
+		confirmTimeout = defaultConfirmTimeout
+	}
+
+	emitter := &Emitter{
+		connection:     conn,
+		channels:       make(chan *confirmChannel, poolSize),
+		confirmTimeout: confirmTimeout,
 	}
-	err := emitter.setup()
+	err := conn.OnConnect(emitter.setup)
 	if err != nil {
-		return Emitter{}, err
+		return nil, err
 	}
 
 	return emitter, nil
@@ -19,7 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Logger_WriteLog_FullMethodName = "/logs.Logger/writeLog"
+	Logger_WriteLog_FullMethodName      = "/logs.Logger/writeLog"
+	Logger_WriteLogs_FullMethodName     = "/logs.Logger/writeLogs"
+	Logger_WriteLogBatch_FullMethodName = "/logs.Logger/writeLogBatch"
+	Logger_SearchLogs_FullMethodName    = "/logs.Logger/searchLogs"
+	Logger_GetLog_FullMethodName        = "/logs.Logger/getLog"
+	Logger_UpdateLog_FullMethodName     = "/logs.Logger/updateLog"
+	Logger_DeleteLog_FullMethodName     = "/logs.Logger/deleteLog"
+	Logger_DeleteLogs_FullMethodName    = "/logs.Logger/deleteLogs"
 )
 
 // LoggerClient is the client API for Logger service.
@@ -27,6 +34,13 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type LoggerClient interface {
 	WriteLog(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (*LogResponse, error)
+	WriteLogs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[LogRequest, BatchResponse], error)
+	WriteLogBatch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	SearchLogs(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*LogPage, error)
+	GetLog(ctx context.Context, in *GetLogRequest, opts ...grpc.CallOption) (*LogRecord, error)
+	UpdateLog(ctx context.Context, in *UpdateLogRequest, opts ...grpc.CallOption) (*UpdateLogResponse, error)
+	DeleteLog(ctx context.Context, in *DeleteLogRequest, opts ...grpc.CallOption) (*DeleteLogsResponse, error)
+	DeleteLogs(ctx context.Context, in *DeleteLogsRequest, opts ...grpc.CallOption) (*DeleteLogsResponse, error)
 }
 
 type loggerClient struct {
@@ -47,11 +61,91 @@ func (c *loggerClient) WriteLog(ctx context.Context, in *LogRequest, opts ...grp
 	return out, nil
 }
 
+func (c *loggerClient) WriteLogs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[LogRequest, BatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Logger_ServiceDesc.Streams[0], Logger_WriteLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[LogRequest, BatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Logger_WriteLogsClient = grpc.ClientStreamingClient[LogRequest, BatchResponse]
+
+func (c *loggerClient) WriteLogBatch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, Logger_WriteLogBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loggerClient) SearchLogs(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*LogPage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LogPage)
+	err := c.cc.Invoke(ctx, Logger_SearchLogs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loggerClient) GetLog(ctx context.Context, in *GetLogRequest, opts ...grpc.CallOption) (*LogRecord, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LogRecord)
+	err := c.cc.Invoke(ctx, Logger_GetLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loggerClient) UpdateLog(ctx context.Context, in *UpdateLogRequest, opts ...grpc.CallOption) (*UpdateLogResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateLogResponse)
+	err := c.cc.Invoke(ctx, Logger_UpdateLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loggerClient) DeleteLog(ctx context.Context, in *DeleteLogRequest, opts ...grpc.CallOption) (*DeleteLogsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteLogsResponse)
+	err := c.cc.Invoke(ctx, Logger_DeleteLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *loggerClient) DeleteLogs(ctx context.Context, in *DeleteLogsRequest, opts ...grpc.CallOption) (*DeleteLogsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteLogsResponse)
+	err := c.cc.Invoke(ctx, Logger_DeleteLogs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LoggerServer is the server API for Logger service.
 // All implementations must embed UnimplementedLoggerServer
 // for forward compatibility.
 type LoggerServer interface {
 	WriteLog(context.Context, *LogRequest) (*LogResponse, error)
+	WriteLogs(grpc.ClientStreamingServer[LogRequest, BatchResponse]) error
+	WriteLogBatch(context.Context, *BatchRequest) (*BatchResponse, error)
+	SearchLogs(context.Context, *SearchRequest) (*LogPage, error)
+	GetLog(context.Context, *GetLogRequest) (*LogRecord, error)
+	UpdateLog(context.Context, *UpdateLogRequest) (*UpdateLogResponse, error)
+	DeleteLog(context.Context, *DeleteLogRequest) (*DeleteLogsResponse, error)
+	DeleteLogs(context.Context, *DeleteLogsRequest) (*DeleteLogsResponse, error)
 	mustEmbedUnimplementedLoggerServer()
 }
 
@@ -65,6 +159,27 @@ type UnimplementedLoggerServer struct{}
 func (UnimplementedLoggerServer) WriteLog(context.Context, *LogRequest) (*LogResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method WriteLog not implemented")
 }
+func (UnimplementedLoggerServer) WriteLogs(grpc.ClientStreamingServer[LogRequest, BatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WriteLogs not implemented")
+}
+func (UnimplementedLoggerServer) WriteLogBatch(context.Context, *BatchRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteLogBatch not implemented")
+}
+func (UnimplementedLoggerServer) SearchLogs(context.Context, *SearchRequest) (*LogPage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchLogs not implemented")
+}
+func (UnimplementedLoggerServer) GetLog(context.Context, *GetLogRequest) (*LogRecord, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLog not implemented")
+}
+func (UnimplementedLoggerServer) UpdateLog(context.Context, *UpdateLogRequest) (*UpdateLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLog not implemented")
+}
+func (UnimplementedLoggerServer) DeleteLog(context.Context, *DeleteLogRequest) (*DeleteLogsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLog not implemented")
+}
+func (UnimplementedLoggerServer) DeleteLogs(context.Context, *DeleteLogsRequest) (*DeleteLogsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLogs not implemented")
+}
 func (UnimplementedLoggerServer) mustEmbedUnimplementedLoggerServer() {}
 func (UnimplementedLoggerServer) testEmbeddedByValue()                {}
 
@@ -104,6 +219,121 @@ func _Logger_WriteLog_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Logger_WriteLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LoggerServer).WriteLogs(&grpc.GenericServerStream[LogRequest, BatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Logger_WriteLogsServer = grpc.ClientStreamingServer[LogRequest, BatchResponse]
+
+func _Logger_WriteLogBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoggerServer).WriteLogBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Logger_WriteLogBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoggerServer).WriteLogBatch(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Logger_SearchLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoggerServer).SearchLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Logger_SearchLogs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoggerServer).SearchLogs(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Logger_GetLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoggerServer).GetLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Logger_GetLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoggerServer).GetLog(ctx, req.(*GetLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Logger_UpdateLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoggerServer).UpdateLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Logger_UpdateLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoggerServer).UpdateLog(ctx, req.(*UpdateLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Logger_DeleteLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoggerServer).DeleteLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Logger_DeleteLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoggerServer).DeleteLog(ctx, req.(*DeleteLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Logger_DeleteLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoggerServer).DeleteLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Logger_DeleteLogs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoggerServer).DeleteLogs(ctx, req.(*DeleteLogsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Logger_ServiceDesc is the grpc.ServiceDesc for Logger service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +345,37 @@ var Logger_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "writeLog",
 			Handler:    _Logger_WriteLog_Handler,
 		},
+		{
+			MethodName: "writeLogBatch",
+			Handler:    _Logger_WriteLogBatch_Handler,
+		},
+		{
+			MethodName: "searchLogs",
+			Handler:    _Logger_SearchLogs_Handler,
+		},
+		{
+			MethodName: "getLog",
+			Handler:    _Logger_GetLog_Handler,
+		},
+		{
+			MethodName: "updateLog",
+			Handler:    _Logger_UpdateLog_Handler,
+		},
+		{
+			MethodName: "deleteLog",
+			Handler:    _Logger_DeleteLog_Handler,
+		},
+		{
+			MethodName: "deleteLogs",
+			Handler:    _Logger_DeleteLogs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "writeLogs",
+			Handler:       _Logger_WriteLogs_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "logs.proto",
 }
@@ -1,27 +1,91 @@
 package event
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	ampq "github.com/rabbitmq/amqp091-go"
 	"log"
-	"net/http"
+	"os"
+	"sync"
+	"time"
 )
 
-type Consumer struct {
-	conn      *ampq.Connection
-	queueName string
+const (
+	tenantHeader     = "x-tenant-id"
+	retryCountHeader = "x-retry-count"
+	// routingKeyHeader keeps the routing key the broker published with,
+	// which retries and replays replace with the queue name.
+	routingKeyHeader = "x-original-routing-key"
+	lastErrorHeader  = "x-last-error"
+)
+
+// publishTimeout bounds the wait for RabbitMQ to confirm a retried or
+// dead-lettered message.
+const publishTimeout = 5 * time.Second
+
+const (
+	defaultWorkers        = 10
+	defaultDrainTimeout   = 30 * time.Second
+	defaultHandlerTimeout = 30 * time.Second
+)
+
+type ConsumerConfig struct {
+	// Queue is the durable queue bound to the topics.
+	Queue string
+	// RetryDelays are the waits before each retry of a message that could
+	// not be logged. Once they are used up the message is dead-lettered.
+	RetryDelays []time.Duration
+	// Workers is the number of messages handled at once, 10 by default.
+	Workers int
+	// Prefetch is the number of unacked messages RabbitMQ delivers ahead,
+	// twice Workers by default.
+	Prefetch int
+	// HandlerConcurrency further limits how many events of a name are
+	// handled at once.
+	HandlerConcurrency map[string]int
+	// DrainTimeout bounds the wait for in-flight messages when Listen is
+	// stopped, 30s by default. Messages still unacked then are redelivered.
+	DrainTimeout time.Duration
+	// HandlerTimeout is the deadline of the context a handler gets, 30s by
+	// default. A handler that runs past it fails and the message is retried.
+	HandlerTimeout time.Duration
 }
 
-type Payload struct {
-	Name string `json:"name"`
-	Data string `json:"data"`
+type Consumer struct {
+	conn     *Connection
+	handlers *Registry
+	config   ConsumerConfig
+	// limits holds a semaphore per payload name in HandlerConcurrency.
+	limits map[string]chan struct{}
 }
 
-func NewConsumer(conn *ampq.Connection) (Consumer, error) {
+// NewConsumer returns a Consumer that binds its queue to the topics of
+// handlers and dispatches the messages to them.
+func NewConsumer(conn *Connection, handlers *Registry, config ConsumerConfig) (Consumer, error) {
+	if config.Workers <= 0 {
+		config.Workers = defaultWorkers
+	}
+	if config.Prefetch <= 0 {
+		config.Prefetch = 2 * config.Workers
+	}
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = defaultDrainTimeout
+	}
+	if config.HandlerTimeout <= 0 {
+		config.HandlerTimeout = defaultHandlerTimeout
+	}
+
 	consumer := Consumer{
-		conn: conn,
+		conn:     conn,
+		handlers: handlers,
+		config:   config,
+		limits:   make(map[string]chan struct{}),
+	}
+	for name, n := range config.HandlerConcurrency {
+		if n > 0 {
+			consumer.limits[name] = make(chan struct{}, n)
+		}
 	}
 	err := consumer.setup()
 	if err != nil {
@@ -38,98 +102,315 @@ func (consumer *Consumer) setup() error {
 		log.Println("error creating channel", err)
 		return err
 	}
+	defer channel.Close()
 
 	return declareExchange(channel)
 }
 
-func (consumer *Consumer) Listen(topics []string) error {
+func (consumer *Consumer) retryQueue(attempt int) string {
+	return fmt.Sprintf("%s.retry.%s", consumer.config.Queue, consumer.config.RetryDelays[attempt])
+}
 
-	channel, err := consumer.conn.Channel()
+func (consumer *Consumer) deadQueue() string {
+	return consumer.config.Queue + ".dead"
+}
+
+// declareTopology declares the queue and its bindings, a delay queue per
+// retry that hands messages back to the queue once their delay expires, and
+// the dead-letter exchange with the queue collecting what it receives.
+// Bindings of topics no longer handled are left in place.
+func (consumer *Consumer) declareTopology(channel *ampq.Channel) error {
+	err := declareExchange(channel)
+	if err != nil {
+		return err
+	}
+	err = declareDeadLetterExchange(channel)
 	if err != nil {
-		log.Println("error creating channel", err)
 		return err
 	}
-	defer channel.Close()
 
-	q, err := declareRandomQueue(channel)
+	_, err = declareDurableQueue(channel, consumer.config.Queue, ampq.Table{
+		"x-dead-letter-exchange": deadLetterExchange,
+	})
 	if err != nil {
-		log.Println("error declaring queue	", err)
 		return err
 	}
+	for _, s := range consumer.handlers.Topics() {
+		err = channel.QueueBind(consumer.config.Queue, s, "logs_topic", false, nil)
+		if err != nil {
+			return err
+		}
+	}
 
-	for _, s := range topics {
-		channel.QueueBind(
-			q.Name, // queue name
-			s,
-			"logs_topic",
-			false,
-			nil, //
-		)
+	for attempt, delay := range consumer.config.RetryDelays {
+		_, err = declareDurableQueue(channel, consumer.retryQueue(attempt), ampq.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": consumer.config.Queue,
+		})
+		if err != nil {
+			return err
+		}
+	}
 
+	_, err = declareDurableQueue(channel, consumer.deadQueue(), nil)
+	if err != nil {
+		return err
+	}
+	return channel.QueueBind(consumer.deadQueue(), "", deadLetterExchange, false, nil)
+}
+
+// Listen consumes until ctx is done, then stops taking messages and
+// waits for the in-flight ones before returning nil. When RabbitMQ goes away
+// it waits for the reconnect, then declares the exchange, queue and bindings
+// again and resumes.
+func (consumer *Consumer) Listen(ctx context.Context) error {
+	for {
+		conn, err := consumer.conn.Wait(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
 		if err != nil {
-			log.Println("error binding queue", err)
 			return err
 		}
+
+		err = consumer.consume(ctx, conn)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Println("stopped consuming, resuming", err)
+
+		// Back off in case the channel rather than the connection failed.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// consume hands messages to a pool of workers until the channel closes or
+// ctx is done, then waits for the workers to finish.
+func (consumer *Consumer) consume(ctx context.Context, conn *ampq.Connection) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		log.Println("error creating channel", err)
+		return err
+	}
+	defer channel.Close()
+
+	// Retries and dead letters are published on the same channel and only
+	// acked once RabbitMQ has confirmed them.
+	err = channel.Confirm(false)
+	if err != nil {
+		log.Println("error enabling publisher confirms", err)
+		return err
 	}
 
-	messages, err := channel.Consume(q.Name, "", true, false, false, false, nil)
+	err = consumer.declareTopology(channel)
+	if err != nil {
+		log.Println("error declaring queues", err)
+		return err
+	}
+
+	err = channel.Qos(consumer.config.Prefetch, 0, false)
+	if err != nil {
+		log.Println("error setting prefetch", err)
+		return err
+	}
+
+	tag := fmt.Sprintf("listener-%d", os.Getpid())
+	messages, err := channel.Consume(consumer.config.Queue, tag, false, false, false, false, nil)
 	if err != nil {
 		log.Println("error consuming messages", err)
 		return err
 	}
 
-	forever := make(chan bool)
-	go func() {
-		for d := range messages {
+	fmt.Printf("waiting for messages [Exchange, Queue]: [logs_topic, %s]\n", consumer.config.Queue)
+
+	jobs := make(chan ampq.Delivery)
+	var wg sync.WaitGroup
+	for range consumer.config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				consumer.handle(channel, d)
+			}
+		}()
+	}
+
+	err = consumer.dispatch(ctx, messages, jobs)
+	if ctx.Err() != nil {
+		channel.Cancel(tag, false)
+	}
+	close(jobs)
+
+	// Messages not handled by now are redelivered once the channel closes.
+	if !waitTimeout(&wg, consumer.config.DrainTimeout) {
+		log.Println("timed out waiting for in-flight messages")
+	}
+
+	return err
+}
+
+// dispatch feeds messages to the workers until the channel closes or ctx is
+// done.
+func (consumer *Consumer) dispatch(ctx context.Context, messages <-chan ampq.Delivery, jobs chan<- ampq.Delivery) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d, ok := <-messages:
+			if !ok {
+				return errors.New("delivery channel closed")
+			}
 			log.Println("received message")
-			var payload Payload
-			_ = json.Unmarshal(d.Body, &payload)
 
-			go handlePayload(payload)
+			select {
+			case jobs <- d:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
+	}
+}
+
+// waitTimeout waits for wg and reports whether it finished within timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
 	}()
 
-	fmt.Printf("waiting for messages [Exchange, Queue]: [logs_topic, %s]\n", q.Name)
-	<-forever
-	return nil
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
 }
 
-func handlePayload(payload Payload) {
-	switch payload.Name {
-	case "log", "event":
-		log.Println("logging event siwtch")
-		err := logEvent(payload)
-		if err != nil {
-			log.Println("error logging item", err)
-		}
-	case "auth":
+// handle acks d once its handler succeeds. A message that fails is sent to
+// the next delay queue, or dead-lettered when its retries are used up; one
+// that cannot be decoded is dead-lettered right away.
+func (consumer *Consumer) handle(channel *ampq.Channel, d ampq.Delivery) {
+	e, err := newEvent(d)
+	if err != nil {
+		consumer.deadLetter(channel, d, err)
+		return
+	}
 
-	default:
-		err := logEvent(payload)
-		if err != nil {
-			log.Println("error logging item", err)
-		}
+	if limit, ok := consumer.limits[e.Name]; ok {
+		limit <- struct{}{}
+		defer func() { <-limit }()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), consumer.config.HandlerTimeout)
+	err = consumer.handlers.Dispatch(ctx, e)
+	cancel()
+	switch {
+	case err == nil:
+		d.Ack(false)
+		return
+	case errors.Is(err, ErrNoHandler):
+		log.Println("dropping message", err)
+		d.Ack(false)
+		return
+	case errors.Is(err, ErrInvalidPayload):
+		consumer.deadLetter(channel, d, err)
+		return
+	}
+	log.Println("error handling event", err)
+
+	attempt := retryCount(d)
+	if attempt >= len(consumer.config.RetryDelays) {
+		consumer.deadLetter(channel, d, err)
+		return
 	}
+
+	headers := forwardHeaders(d, err)
+	headers[retryCountHeader] = int32(attempt + 1)
+	err = publish(channel, "", consumer.retryQueue(attempt), d, headers)
+	if err != nil {
+		log.Println("error scheduling retry", err)
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
 }
 
-func logEvent(p Payload) error {
-	jsonData, _ := json.MarshalIndent(p, "", "\t")
-	request, err := http.NewRequest("POST", "http://logger-service/log", bytes.NewBuffer(jsonData))
+// deadLetter publishes d to the dead-letter exchange with the reason it
+// failed. If that fails, rejecting d has RabbitMQ dead-letter it instead,
+// without the reason.
+func (consumer *Consumer) deadLetter(channel *ampq.Channel, d ampq.Delivery, reason error) {
+	log.Println("dead-lettering message", reason)
+
+	err := publish(channel, deadLetterExchange, routingKey(d), d, forwardHeaders(d, reason))
 	if err != nil {
-		return err
+		log.Println("error publishing dead letter", err)
+		d.Nack(false, false)
+		return
+	}
+	d.Ack(false)
+}
+
+// routingKey returns the key d was first published with.
+func routingKey(d ampq.Delivery) string {
+	if key, ok := d.Headers[routingKeyHeader].(string); ok {
+		return key
 	}
-	log.Println("logging event")
+	return d.RoutingKey
+}
 
-	request.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	response, err := client.Do(request)
+func retryCount(d ampq.Delivery) int {
+	switch n := d.Headers[retryCountHeader].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	}
+	return 0
+}
+
+// forwardHeaders copies the headers of d for republishing it, recording its
+// original routing key and, if not nil, why it failed.
+func forwardHeaders(d ampq.Delivery, reason error) ampq.Table {
+	headers := ampq.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[routingKeyHeader] = routingKey(d)
+	if reason != nil {
+		headers[lastErrorHeader] = reason.Error()
+	}
+	return headers
+}
+
+// publish republishes the body of d and waits for RabbitMQ to confirm it.
+// channel must be in confirm mode.
+func publish(channel *ampq.Channel, exchange, key string, d ampq.Delivery, headers ampq.Table) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	confirm, err := channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, ampq.Publishing{
+		ContentType:  d.ContentType,
+		DeliveryMode: ampq.Persistent,
+		Timestamp:    d.Timestamp,
+		Headers:      headers,
+		Body:         d.Body,
+	})
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusAccepted {
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
 		return err
 	}
+	if !acked {
+		return errors.New("message was not acknowledged by rabbitmq")
+	}
 	return nil
 }
@@ -0,0 +1,118 @@
+package event
+
+import (
+	"fmt"
+	ampq "github.com/rabbitmq/amqp091-go"
+	"time"
+)
+
+// maxDeadLetters bounds how many dead letters one call reads or replays.
+const maxDeadLetters = 1000
+
+type DeadLetter struct {
+	RoutingKey string `json:"routing_key"`
+	Tenant     string `json:"tenant,omitempty"`
+	Retries    int    `json:"retries"`
+	// Error is why the listener gave up on the message. Messages that
+	// RabbitMQ dead-lettered itself have a Reason instead.
+	Error       string    `json:"error,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+	Body        string    `json:"body"`
+}
+
+func newDeadLetter(d ampq.Delivery) DeadLetter {
+	letter := DeadLetter{
+		RoutingKey:  routingKey(d),
+		Retries:     retryCount(d),
+		PublishedAt: d.Timestamp,
+		Body:        string(d.Body),
+	}
+	letter.Tenant, _ = d.Headers[tenantHeader].(string)
+	letter.Error, _ = d.Headers[lastErrorHeader].(string)
+	if deaths, ok := d.Headers["x-death"].([]any); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(ampq.Table); ok {
+			letter.Reason, _ = death["reason"].(string)
+		}
+	}
+	return letter
+}
+
+func deadLetterLimit(limit int) int {
+	if limit <= 0 || limit > maxDeadLetters {
+		return maxDeadLetters
+	}
+	return limit
+}
+
+// DeadLetters returns up to limit dead letters, oldest first, and leaves
+// them in the dead-letter queue.
+func (consumer *Consumer) DeadLetters(limit int) ([]DeadLetter, error) {
+	channel, err := consumer.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	// Closing the channel requeues the unacked messages read below.
+	defer channel.Close()
+
+	letters := []DeadLetter{}
+	for len(letters) < deadLetterLimit(limit) {
+		d, ok, err := channel.Get(consumer.deadQueue(), false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, newDeadLetter(d))
+	}
+
+	return letters, nil
+}
+
+// ReplayDeadLetters moves up to limit dead letters, oldest first, back to
+// the queue with their retries reset. It returns how many it moved; on
+// error the rest stay in the dead-letter queue.
+func (consumer *Consumer) ReplayDeadLetters(limit int) (int, error) {
+	channel, err := consumer.conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer channel.Close()
+
+	err = channel.Confirm(false)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for replayed < deadLetterLimit(limit) {
+		d, ok, err := channel.Get(consumer.deadQueue(), false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+
+		headers := forwardHeaders(d, nil)
+		delete(headers, retryCountHeader)
+		delete(headers, lastErrorHeader)
+		delete(headers, "x-death")
+		delete(headers, "x-first-death-exchange")
+		delete(headers, "x-first-death-queue")
+		delete(headers, "x-first-death-reason")
+
+		err = publish(channel, "", consumer.config.Queue, d, headers)
+		if err != nil {
+			return replayed, fmt.Errorf("error replaying dead letter: %w", err)
+		}
+		err = d.Ack(false)
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
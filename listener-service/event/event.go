@@ -2,6 +2,9 @@ package event
 
 import ampq "github.com/rabbitmq/amqp091-go"
 
+// deadLetterExchange receives the messages the listener gave up on.
+const deadLetterExchange = "logs_dlx"
+
 func declareExchange(ch *ampq.Channel) error {
 	return ch.ExchangeDeclare(
 		"logs_topic", // name
@@ -14,13 +17,25 @@ func declareExchange(ch *ampq.Channel) error {
 	)
 }
 
-func declareRandomQueue(ch *ampq.Channel) (ampq.Queue, error) {
+func declareDeadLetterExchange(ch *ampq.Channel) error {
+	return ch.ExchangeDeclare(
+		deadLetterExchange, // name
+		"fanout",           // type
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+}
+
+func declareDurableQueue(ch *ampq.Channel, name string, args ampq.Table) (ampq.Queue, error) {
 	return ch.QueueDeclare(
-		"",    // name
-		false, // durable
+		name,  // name
+		true,  // durable
 		false, // delete when unused
-		true,  // exclusive
+		false, // exclusive
 		false, // no-wait
-		nil,   // arguments
+		args,  // arguments
 	)
 }
@@ -0,0 +1,58 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// loggerClient bounds a call to the logger even when the handler's context
+// has no deadline.
+var loggerClient = &http.Client{Timeout: 10 * time.Second}
+
+type Payload struct {
+	Name  string `json:"name"`
+	Data  string `json:"data"`
+	Level string `json:"level,omitempty"`
+}
+
+// LogEvent sends the event to the logger. The level defaults to the last
+// word of the routing key and the tenant is forwarded as the X-Tenant-ID
+// HTTP header.
+func LogEvent(ctx context.Context, e Event, p Payload) error {
+	if p.Level == "" {
+		p.Level = strings.TrimPrefix(e.RoutingKey, "log.")
+	}
+
+	jsonData, _ := json.MarshalIndent(p, "", "\t")
+	request, err := http.NewRequestWithContext(ctx, "POST", "http://logger-service/log", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	log.Println("logging event")
+
+	request.Header.Set("Content-Type", "application/json")
+	if e.Tenant != "" {
+		request.Header.Set("X-Tenant-ID", e.Tenant)
+	}
+	response, err := loggerClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("logger service returned status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// IgnoreEvent acks the event and does nothing else.
+func IgnoreEvent(_ context.Context, _ Event, _ json.RawMessage) error {
+	return nil
+}
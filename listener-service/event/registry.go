@@ -0,0 +1,143 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	ampq "github.com/rabbitmq/amqp091-go"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrInvalidPayload marks messages that cannot be decoded. They are
+	// dead-lettered without retries.
+	ErrInvalidPayload = errors.New("invalid payload")
+	// ErrNoHandler marks messages no handler subscribed to. They are
+	// dropped.
+	ErrNoHandler = errors.New("no handler for event")
+)
+
+// Event is a message as handlers see it, before its payload is decoded.
+type Event struct {
+	// Name is the name field of the JSON body.
+	Name string
+	// RoutingKey is the key the message was first published with.
+	RoutingKey string
+	Tenant     string
+	Body       []byte
+}
+
+func newEvent(d ampq.Delivery) (Event, error) {
+	var envelope struct {
+		Name string `json:"name"`
+	}
+	err := json.Unmarshal(d.Body, &envelope)
+	if err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	e := Event{
+		Name:       envelope.Name,
+		RoutingKey: routingKey(d),
+		Body:       d.Body,
+	}
+	e.Tenant, _ = d.Headers[tenantHeader].(string)
+
+	return e, nil
+}
+
+type subscription struct {
+	pattern string
+	name    string
+	handle  func(context.Context, Event) error
+}
+
+// Registry routes events to the handlers subscribed to them. The patterns
+// of the subscriptions are the topics the consumer binds its queue to.
+type Registry struct {
+	subscriptions []subscription
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Handle subscribes fn to the events named name whose routing key matches
+// pattern, with * standing for one word and # for zero or more as in AMQP
+// bindings. An empty name subscribes to the events no other subscription
+// names. The body is decoded into a T before fn is called; a body that does
+// not decode is an ErrInvalidPayload.
+func Handle[T any](r *Registry, pattern, name string, fn func(ctx context.Context, e Event, payload T) error) {
+	r.subscriptions = append(r.subscriptions, subscription{
+		pattern: pattern,
+		name:    name,
+		handle: func(ctx context.Context, e Event) error {
+			var payload T
+			err := json.Unmarshal(e.Body, &payload)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+			}
+			return fn(ctx, e, payload)
+		},
+	})
+}
+
+// Topics returns the patterns subscribed to, each once.
+func (r *Registry) Topics() []string {
+	var topics []string
+	for _, s := range r.subscriptions {
+		if !slices.Contains(topics, s.pattern) {
+			topics = append(topics, s.pattern)
+		}
+	}
+	return topics
+}
+
+// Dispatch runs the first handler subscribed to e by name, or failing that
+// the first one subscribed to all names.
+func (r *Registry) Dispatch(ctx context.Context, e Event) error {
+	var fallback *subscription
+	for i, s := range r.subscriptions {
+		if !topicMatches(s.pattern, e.RoutingKey) {
+			continue
+		}
+		if s.name == e.Name {
+			return s.handle(ctx, e)
+		}
+		if s.name == "" && fallback == nil {
+			fallback = &r.subscriptions[i]
+		}
+	}
+
+	if fallback == nil {
+		return fmt.Errorf("%w %q on %s", ErrNoHandler, e.Name, e.RoutingKey)
+	}
+	return fallback.handle(ctx, e)
+}
+
+// topicMatches reports whether key matches the binding pattern.
+func topicMatches(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && key[0] == pattern[0] && matchWords(pattern[1:], key[1:])
+	}
+}
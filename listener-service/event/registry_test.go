@@ -0,0 +1,98 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"log.INFO", "log.INFO", true},
+		{"log.INFO", "log.ERROR", false},
+		{"log.*", "log.DEBUG", true},
+		{"log.*", "log.FATAL", true},
+		{"log.*", "log", false},
+		{"log.*", "log.a.b", false},
+		{"*.ERROR", "auth.ERROR", true},
+		{"log.#", "log", true},
+		{"log.#", "log.a.b", true},
+		{"#", "anything.at.all", true},
+		{"#.ERROR", "ERROR", true},
+		{"#.ERROR", "a.b.ERROR", true},
+		{"#.ERROR", "a.ERROR.b", false},
+		{"log.#.end", "log.end", true},
+		{"log.#.end", "log.x.y.end", true},
+		{"log.#.end", "log.x.y", false},
+		{"log", "logs", false},
+	}
+
+	for _, tt := range tests {
+		if got := topicMatches(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	var called []string
+	record := func(name string) func(context.Context, Event, json.RawMessage) error {
+		return func(context.Context, Event, json.RawMessage) error {
+			called = append(called, name)
+			return nil
+		}
+	}
+
+	registry := NewRegistry()
+	Handle(registry, "log.*", "auth", record("ignore auth"))
+	Handle(registry, "log.*", "", record("log"))
+	Handle(registry, "log.*", "", record("second fallback"))
+	Handle(registry, "audit.#", "login", record("audit login"))
+
+	if topics := registry.Topics(); !slices.Equal(topics, []string{"log.*", "audit.#"}) {
+		t.Errorf("Topics() = %v, want each pattern once", topics)
+	}
+
+	tests := []struct {
+		key  string
+		name string
+		want string
+		err  error
+	}{
+		{key: "log.INFO", name: "auth", want: "ignore auth"},
+		{key: "log.DEBUG", name: "payment", want: "log"},
+		{key: "log.CRITICAL", name: "", want: "log"},
+		{key: "audit.eu.login", name: "login", want: "audit login"},
+		{key: "audit.eu.login", name: "logout", err: ErrNoHandler},
+		{key: "metrics.cpu", name: "auth", err: ErrNoHandler},
+	}
+
+	for _, tt := range tests {
+		called = nil
+		body, _ := json.Marshal(Payload{Name: tt.name})
+		err := registry.Dispatch(context.Background(), Event{Name: tt.name, RoutingKey: tt.key, Body: body})
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s %q: error = %v, want %v", tt.key, tt.name, err, tt.err)
+		}
+		if tt.err == nil && !slices.Equal(called, []string{tt.want}) {
+			t.Errorf("%s %q: called %v, want %s", tt.key, tt.name, called, tt.want)
+		}
+	}
+}
+
+func TestDispatchInvalidPayload(t *testing.T) {
+	registry := NewRegistry()
+	Handle(registry, "log.*", "", func(context.Context, Event, Payload) error { return nil })
+
+	err := registry.Dispatch(context.Background(), Event{RoutingKey: "log.INFO", Body: []byte(`{"name": 1}`)})
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("error = %v, want ErrInvalidPayload", err)
+	}
+}
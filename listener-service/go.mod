@@ -2,4 +2,7 @@ module listener-service
 
 go 1.23.2
 
-require github.com/rabbitmq/amqp091-go v1.10.0 // indirect
+require (
+	github.com/go-chi/chi/v5 v5.1.0
+	github.com/rabbitmq/amqp091-go v1.10.0
+)
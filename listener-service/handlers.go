@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"listener-service/event"
+	"net/http"
+	"strconv"
+)
+
+type ReplayResult struct {
+	Replayed int `json:"replayed"`
+}
+
+// readLimit reads the optional limit query parameter.
+func readLimit(r *http.Request) (int, error) {
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return 0, errors.New("invalid limit")
+		}
+	}
+	return limit, nil
+}
+
+// rabbitStatus answers 503 while RabbitMQ is being reconnected.
+func rabbitStatus(err error) int {
+	if errors.Is(err, event.ErrDisconnected) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// DeadLetters lists the dead letters without removing them.
+func (app *Config) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, err := readLimit(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	letters, err := app.Consumer.DeadLetters(limit)
+	if err != nil {
+		app.errorJSON(w, err, rabbitStatus(err))
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "dead letters",
+		Data:    letters,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// ReplayDeadLetters sends dead letters back to the listener's queue.
+func (app *Config) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, err := readLimit(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	replayed, err := app.Consumer.ReplayDeadLetters(limit)
+	if err != nil {
+		app.writeJSON(w, rabbitStatus(err), jsonResponse{
+			Error:   true,
+			Message: err.Error(),
+			Data:    ReplayResult{Replayed: replayed},
+		})
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "replayed dead letters",
+		Data:    ReplayResult{Replayed: replayed},
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+)
+
+func (app *Config) routes() http.Handler {
+	mux := chi.NewRouter()
+
+	mux.Use(middleware.Heartbeat("/ping"))
+
+	mux.Route("/admin/dead-letters", func(mux chi.Router) {
+		mux.Get("/", app.DeadLetters)
+		mux.Post("/replay", app.ReplayDeadLetters)
+	})
+
+	return mux
+}
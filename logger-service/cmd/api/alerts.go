@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"log-service/data"
+	"net/http"
+	"os"
+	"time"
+)
+
+type AlertRulePayload struct {
+	Description   string `json:"description"`
+	Name          string `json:"name"`
+	Level         string `json:"level"`
+	Threshold     int    `json:"threshold"`
+	WindowMinutes int    `json:"window_minutes"`
+	Email         string `json:"email"`
+	Enabled       *bool  `json:"enabled,omitempty"`
+}
+
+type SilencePayload struct {
+	Minutes int `json:"minutes"`
+}
+
+type mailMessage struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+}
+
+// sendAlertMail emails a fired alert through the mail service. Rules without
+// an address go to ALERT_EMAIL.
+func sendAlertMail(alert data.Alert) error {
+	to := alert.Rule.Email
+	if to == "" {
+		to = os.Getenv("ALERT_EMAIL")
+	}
+	if to == "" {
+		return errors.New("alert rule has no email and ALERT_EMAIL is not set")
+	}
+
+	subject := fmt.Sprintf("[alert] %s", alert.Rule.Description)
+	if alert.Rule.Description == "" {
+		subject = fmt.Sprintf("[alert] more than %d %s %s logs in %d minutes", alert.Rule.Threshold, alert.Rule.Level, alert.Rule.Name, alert.Rule.WindowMinutes)
+	}
+
+	message := fmt.Sprintf(
+		"Alert rule %s of tenant %s fired at %s.\n\nMore than %d entries matching name %q and level %q arrived within %d minutes.\n\nLatest entry: %s: %s",
+		alert.Rule.ID,
+		alert.Tenant,
+		alert.FiredAt.Format(time.RFC3339),
+		alert.Rule.Threshold,
+		alert.Rule.Name,
+		alert.Rule.Level,
+		alert.Rule.WindowMinutes,
+		alert.Sample.Name,
+		alert.Sample.Data,
+	)
+
+	jsonData, _ := json.MarshalIndent(mailMessage{
+		To:      to,
+		Subject: subject,
+		Message: message,
+	}, "", "\t")
+
+	request, err := http.NewRequest("POST", "http://mail-service/send", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("mail service returned %s", response.Status)
+	}
+
+	return nil
+}
+
+func (app *Config) AlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := app.Alerts.Rules(r.Context())
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Alert rules",
+		Data:    rules,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+func (app *Config) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := app.Alerts.Rule(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, errors.New("alert rule not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Alert rule",
+		Data:    rule,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// SaveAlertRule creates a rule on POST /admin/alerts and replaces one on
+// PUT /admin/alerts/{id}. New rules are enabled unless enabled is false.
+func (app *Config) SaveAlertRule(w http.ResponseWriter, r *http.Request) {
+	var requestPayload AlertRulePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	rule := data.AlertRule{
+		ID:            chi.URLParam(r, "id"),
+		Description:   requestPayload.Description,
+		Name:          requestPayload.Name,
+		Level:         requestPayload.Level,
+		Threshold:     requestPayload.Threshold,
+		WindowMinutes: requestPayload.WindowMinutes,
+		Email:         requestPayload.Email,
+		Enabled:       requestPayload.Enabled == nil || *requestPayload.Enabled,
+	}
+
+	saved, err := app.Alerts.SaveRule(r.Context(), rule)
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, errors.New("alert rule not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Alert rule saved",
+		Data:    saved,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (app *Config) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	err := app.Alerts.DeleteRule(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, errors.New("alert rule not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Alert rule deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// SilenceAlertRule mutes a rule for the given number of minutes; zero
+// minutes lifts the silence.
+func (app *Config) SilenceAlertRule(w http.ResponseWriter, r *http.Request) {
+	var requestPayload SilencePayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	if requestPayload.Minutes < 0 {
+		app.errorJSON(w, errors.New("minutes must not be negative"))
+		return
+	}
+
+	var until time.Time
+	if requestPayload.Minutes > 0 {
+		until = time.Now().Add(time.Duration(requestPayload.Minutes) * time.Minute)
+	}
+
+	rule, err := app.Alerts.Silence(r.Context(), chi.URLParam(r, "id"), until)
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, errors.New("alert rule not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Alert rule silenced",
+		Data:    rule,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
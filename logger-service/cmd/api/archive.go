@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log-service/data"
+	"net/http"
+	"os"
+	"time"
+)
+
+type ArchiveRunPayload struct {
+	// Days overrides LOG_ARCHIVE_AFTER_DAYS for this run.
+	Days int `json:"days"`
+}
+
+type RestorePayload struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// archiveJob archives entries older than app.ArchiveAfterDays every interval
+// until the process exits.
+func (app *Config) archiveJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().AddDate(0, 0, -app.ArchiveAfterDays)
+		run, err := app.Archive.ArchiveAll(context.Background(), cutoff)
+		if err != nil {
+			log.Println("Error archiving logs", err)
+			continue
+		}
+		if run.Archived > 0 {
+			log.Printf("Archived %d log entries into %d files", run.Archived, len(run.Parts))
+		}
+	}
+}
+
+// parseArchiveRange parses the inclusive YYYY-MM-DD dates of a restore.
+func parseArchiveRange(from, to string) (time.Time, time.Time, error) {
+	first, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, time.Time{}, data.ErrInvalidArchiveRange
+	}
+	last, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return time.Time{}, time.Time{}, data.ErrInvalidArchiveRange
+	}
+	return first, last, nil
+}
+
+func (app *Config) archiveEnabled(w http.ResponseWriter) bool {
+	if app.Archive == nil {
+		app.errorJSON(w, errors.New("archiving is off"), http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func (app *Config) ArchiveManifest(w http.ResponseWriter, r *http.Request) {
+	if !app.archiveEnabled(w) {
+		return
+	}
+
+	manifest, err := app.Archive.Manifest(r.Context())
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Archive manifest",
+		Data:    manifest,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// RunArchive archives the caller's tenant immediately instead of waiting for
+// the next run.
+func (app *Config) RunArchive(w http.ResponseWriter, r *http.Request) {
+	if !app.archiveEnabled(w) {
+		return
+	}
+
+	var requestPayload ArchiveRunPayload
+	if r.ContentLength != 0 {
+		if err := app.readJSON(w, r, &requestPayload); err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+	}
+
+	days := app.ArchiveAfterDays
+	if requestPayload.Days > 0 {
+		days = requestPayload.Days
+	}
+
+	run, err := app.Archive.Archive(r.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: fmt.Sprintf("Archived %d log entries", run.Archived),
+		Data:    run,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// RestoreArchive reimports the archived entries of the caller's tenant for
+// the days from to to.
+func (app *Config) RestoreArchive(w http.ResponseWriter, r *http.Request) {
+	if !app.archiveEnabled(w) {
+		return
+	}
+
+	var requestPayload RestorePayload
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	from, to, err := parseArchiveRange(requestPayload.From, requestPayload.To)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	restored, err := app.Archive.Restore(r.Context(), data.TenantFrom(r.Context()), from, to)
+	if errors.Is(err, data.ErrInvalidArchiveRange) {
+		app.errorJSON(w, err)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: fmt.Sprintf("Restored %d log entries", restored),
+		Data:    restored,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// restoreCommand implements `restore -from YYYY-MM-DD -to YYYY-MM-DD
+// [-tenant id] [-dir path]`, reimporting archived entries into the store
+// named by LOG_STORE. With the file store, stop the logger first: both
+// processes would append to the same journal.
+func restoreCommand(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	from := flags.String("from", "", "first day to restore, YYYY-MM-DD")
+	to := flags.String("to", "", "last day to restore, YYYY-MM-DD (defaults to -from)")
+	tenant := flags.String("tenant", data.DefaultTenant, "tenant to restore")
+	dir := flags.String("dir", os.Getenv("LOG_ARCHIVE_DIR"), "archive directory")
+	_ = flags.Parse(args)
+
+	if *to == "" {
+		*to = *from
+	}
+	first, last, err := parseArchiveRange(*from, *to)
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		return errors.New("no archive directory: set -dir or LOG_ARCHIVE_DIR")
+	}
+	if !data.ValidTenant(*tenant) {
+		return data.ErrInvalidTenant
+	}
+
+	store, closeStore, err := openStore(os.Getenv("LOG_STORE"))
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	archiver, err := data.NewArchiver(store, *dir)
+	if err != nil {
+		return err
+	}
+
+	restored, err := archiver.Restore(context.Background(), *tenant, first, last)
+	log.Printf("Restored %d log entries of tenant %s from %s to %s", restored, *tenant, *from, *to)
+	return err
+}
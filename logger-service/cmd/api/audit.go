@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// VerifyAudit walks the audit chain. It answers 409 with the first broken
+// link when the chain does not check out.
+func (app *Config) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	if app.Audit == nil {
+		app.errorJSON(w, errors.New("audit mode is off"), http.StatusNotFound)
+		return
+	}
+
+	report, err := app.Audit.Verify(r.Context())
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if !report.Intact {
+		resp := jsonResponse{
+			Error:   true,
+			Message: "Audit chain broken at entry " + strconv.FormatInt(report.Broken.Seq, 10) + ": " + report.Broken.Reason,
+			Data:    report,
+		}
+		app.writeJSON(w, http.StatusConflict, resp)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Audit chain intact",
+		Data:    report,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"log-service/data"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type UpdateEntryPayload struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+	// UpdatedAt, when set, must match the stored entry; the update is
+	// refused with 409 if the entry changed since it was read.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DeleteEntriesResult struct {
+	Count  int64 `json:"count"`
+	DryRun bool  `json:"dry_run"`
+}
+
+// entryError writes err with the status matching the data error behind it.
+func (app *Config) entryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		app.errorJSON(w, errors.New("log entry not found"), http.StatusNotFound)
+	case errors.Is(err, data.ErrConflict), errors.Is(err, data.ErrAuditImmutable):
+		app.errorJSON(w, err, http.StatusConflict)
+	case errors.Is(err, data.ErrRedactionRejected):
+		app.errorJSON(w, err, http.StatusUnprocessableEntity)
+	case errors.Is(err, data.ErrFilterRequired):
+		app.errorJSON(w, err)
+	default:
+		app.errorJSON(w, err, http.StatusInternalServerError)
+	}
+}
+
+func (app *Config) GetLogEntry(w http.ResponseWriter, r *http.Request) {
+	entry, err := app.Models.Logs.GetOne(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		app.entryError(w, err)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Log entry",
+		Data:    entry,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// UpdateLogEntry rewrites the name and data of an entry. Send back the
+// updated_at of the entry as read to guard against concurrent changes.
+func (app *Config) UpdateLogEntry(w http.ResponseWriter, r *http.Request) {
+	var requestPayload UpdateEntryPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	if requestPayload.Name == "" {
+		app.errorJSON(w, errors.New("name is required"))
+		return
+	}
+
+	result, err := app.Models.Logs.Update(r.Context(), data.LogEntry{
+		ID:        chi.URLParam(r, "id"),
+		Name:      requestPayload.Name,
+		Data:      requestPayload.Data,
+		UpdatedAt: requestPayload.UpdatedAt,
+	})
+	if err != nil {
+		app.entryError(w, err)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Log entry updated",
+		Data:    result,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (app *Config) DeleteLogEntry(w http.ResponseWriter, r *http.Request) {
+	err := app.Models.Entries.Delete(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		app.entryError(w, err)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Log entry deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// DeleteLogEntries removes the entries matching name, level, from and to.
+// With dry_run=true it only counts them.
+func (app *Config) DeleteLogEntries(w http.ResponseWriter, r *http.Request) {
+	q, err := app.readLogQuery(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var dryRun bool
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			app.errorJSON(w, errors.New("dry_run must be true or false"))
+			return
+		}
+	}
+
+	count, err := app.Models.Entries.DeleteMany(r.Context(), q, dryRun)
+	if err != nil {
+		app.entryError(w, err)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Log entries deleted",
+		Data:    DeleteEntriesResult{Count: count, DryRun: dryRun},
+	}
+	if dryRun {
+		resp.Message = "Log entries matching"
+		app.writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log-service/data"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// exportCursorHeader carries the id of the last exported entry. It is sent
+// as a trailer because it is only known once the stream has finished; pass
+// it back as ?cursor= to continue an export.
+const exportCursorHeader = "X-Export-Cursor"
+
+const exportFlushEvery = 500
+
+// ExportLogs streams the entries matching the query filters as NDJSON or
+// CSV straight from the Mongo cursor. Optional parameters: gzip=true to
+// compress the body, limit to cap the number of entries, and cursor to
+// resume after the entry with that id.
+func (app *Config) ExportLogs(w http.ResponseWriter, r *http.Request) {
+	q, err := app.readLogQuery(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	values := r.URL.Query()
+
+	format := values.Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	if format != "ndjson" && format != "csv" {
+		app.errorJSON(w, errors.New("format must be ndjson or csv"))
+		return
+	}
+
+	var limit int64
+	if v := values.Get("limit"); v != "" {
+		if limit, err = strconv.ParseInt(v, 10, 64); err != nil || limit < 0 {
+			app.errorJSON(w, errors.New("invalid limit"))
+			return
+		}
+	}
+
+	compress, _ := strconv.ParseBool(values.Get("gzip"))
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=logs-"+time.Now().UTC().Format("20060102T150405")+"."+format)
+	w.Header().Set("Trailer", exportCursorHeader)
+
+	var write func(*data.LogEntry) error
+	var csvWriter *csv.Writer
+
+	if format == "csv" {
+		csvWriter = csv.NewWriter(out)
+		_ = csvWriter.Write([]string{"id", "name", "level", "data", "host", "facility", "created_at", "updated_at"})
+		write = func(entry *data.LogEntry) error {
+			return csvWriter.Write([]string{
+				entry.ID,
+				entry.Name,
+				entry.Level,
+				entry.Data,
+				entry.Host,
+				entry.Facility,
+				entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+				entry.UpdatedAt.UTC().Format(time.RFC3339Nano),
+			})
+		}
+	} else {
+		enc := json.NewEncoder(out)
+		write = func(entry *data.LogEntry) error {
+			return enc.Encode(entry)
+		}
+	}
+
+	flusher, _ := w.(http.Flusher)
+	written := 0
+	started := false
+
+	last, err := app.Models.Logs.Export(r.Context(), q, values.Get("cursor"), limit, func(entry *data.LogEntry) error {
+		started = true
+		if err := write(entry); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			if csvWriter != nil {
+				csvWriter.Flush()
+			}
+			if gz != nil {
+				_ = gz.Flush()
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		return nil
+	})
+	if err != nil && !started {
+		// Nothing has been sent yet, so the client can still get an error.
+		w.Header().Del("Content-Encoding")
+		w.Header().Del("Content-Disposition")
+		w.Header().Del("Trailer")
+		if errors.Is(err, data.ErrInvalidCursor) {
+			app.errorJSON(w, err)
+			return
+		}
+		log.Println("Error exporting logs", err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+	}
+	if gz != nil {
+		_ = gz.Close()
+	}
+
+	if err != nil {
+		// The response is already streaming, so the client can only tell
+		// from the missing trailer and resume from the last id it received.
+		log.Println("Error exporting logs after", written, "entries", err)
+		return
+	}
+
+	w.Header().Set(exportCursorHeader, last)
+}
@@ -2,11 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
 	"log"
 	"log-service/data"
 	"log-service/logs"
 	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// streamChunkSize is the number of streamed entries written per
+	// InsertMany call.
+	streamChunkSize = 500
+	// maxStreamEntries bounds the entries one WriteLogs stream may send.
+	maxStreamEntries = 100000
 )
 
 type LogServer struct {
@@ -17,11 +38,12 @@ type LogServer struct {
 func (l *LogServer) WriteLog(ctx context.Context, request *logs.LogRequest) (*logs.LogResponse, error) {
 	input := request.GetLogEntry()
 	logEntry := data.LogEntry{
-		Name: input.Name,
-		Data: input.Data,
+		Name:  input.Name,
+		Data:  input.Data,
+		Level: input.Level,
 	}
 
-	err := l.Models.LogEntry.Insert(logEntry)
+	err := l.Models.Logs.Insert(ctx, logEntry)
 	if err != nil {
 		res := &logs.LogResponse{Message: "Failed"}
 		return res, err
@@ -31,15 +53,296 @@ func (l *LogServer) WriteLog(ctx context.Context, request *logs.LogRequest) (*lo
 	return res, nil
 }
 
+// WriteLogs inserts the stream in chunks of streamChunkSize entries as they
+// arrive, and refuses streams longer than maxStreamEntries. Chunks written
+// before a stream is refused stay written.
+func (l *LogServer) WriteLogs(stream logs.Logger_WriteLogsServer) error {
+	res := &logs.BatchResponse{}
+	chunk := make([]*logs.Log, 0, streamChunkSize)
+	received := 0
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Println("Error receiving log stream", err)
+			return err
+		}
+		if received == maxStreamEntries {
+			return status.Errorf(codes.ResourceExhausted,
+				"stream exceeds %d entries; %d were logged", maxStreamEntries, res.Inserted)
+		}
+
+		chunk = append(chunk, request.GetLogEntry())
+		received++
+		if len(chunk) == streamChunkSize {
+			if err := l.writeBatch(stream.Context(), chunk, received-len(chunk), res); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+		}
+	}
+
+	if err := l.writeBatch(stream.Context(), chunk, received-len(chunk), res); err != nil {
+		return err
+	}
+	res.Message = fmt.Sprintf("Logged %d of %d entries", res.Inserted, received)
+
+	return stream.SendAndClose(res)
+}
+
+func (l *LogServer) WriteLogBatch(ctx context.Context, request *logs.BatchRequest) (*logs.BatchResponse, error) {
+	entries := request.GetLogEntries()
+
+	res := &logs.BatchResponse{}
+	if err := l.writeBatch(ctx, entries, 0, res); err != nil {
+		return &logs.BatchResponse{Message: "Failed"}, err
+	}
+	res.Message = fmt.Sprintf("Logged %d of %d entries", res.Inserted, len(entries))
+
+	return res, nil
+}
+
+// writeBatch inserts the valid entries in one InsertMany call and adds the
+// outcome of every entry to res, indexed from offset.
+func (l *LogServer) writeBatch(ctx context.Context, entries []*logs.Log, offset int, res *logs.BatchResponse) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	results := make([]*logs.BatchResult, len(entries))
+
+	var batch []data.LogEntry
+	var positions []int
+	for i, input := range entries {
+		results[i] = &logs.BatchResult{Index: int32(offset + i)}
+		if input.GetName() == "" {
+			results[i].Error = "log entry name is required"
+			continue
+		}
+		batch = append(batch, data.LogEntry{
+			Name:  input.GetName(),
+			Data:  input.GetData(),
+			Level: input.GetLevel(),
+		})
+		positions = append(positions, i)
+	}
+
+	itemErrors, err := l.Models.Logs.InsertMany(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	for i, itemErr := range itemErrors {
+		if itemErr != nil {
+			results[positions[i]].Error = itemErr.Error()
+			continue
+		}
+		results[positions[i]].Ok = true
+	}
+
+	for _, result := range results {
+		if result.Ok {
+			res.Inserted++
+		} else {
+			res.Failed++
+		}
+	}
+	res.Results = append(res.Results, results...)
+
+	return nil
+}
+
+func (l *LogServer) SearchLogs(ctx context.Context, request *logs.SearchRequest) (*logs.LogPage, error) {
+	if request.GetQuery() == "" && request.GetPhrase() == "" {
+		return nil, status.Error(codes.InvalidArgument, "query or phrase is required")
+	}
+
+	q := data.LogQuery{
+		Name:     request.GetName(),
+		Page:     int(request.GetPage()),
+		PageSize: int(request.GetPageSize()),
+	}
+	if request.GetFrom() != nil {
+		q.From = request.GetFrom().AsTime()
+	}
+	if request.GetTo() != nil {
+		q.To = request.GetTo().AsTime()
+	}
+
+	page, err := l.Models.Logs.Search(ctx, request.GetQuery(), request.GetPhrase(), q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toLogPage(page), nil
+}
+
+func (l *LogServer) GetLog(ctx context.Context, request *logs.GetLogRequest) (*logs.LogRecord, error) {
+	entry, err := l.Models.Logs.GetOne(ctx, request.GetId())
+	if err != nil {
+		return nil, entryStatus(err)
+	}
+
+	return toLogRecord(entry), nil
+}
+
+func (l *LogServer) UpdateLog(ctx context.Context, request *logs.UpdateLogRequest) (*logs.UpdateLogResponse, error) {
+	if request.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	entry := data.LogEntry{
+		ID:   request.GetId(),
+		Name: request.GetName(),
+		Data: request.GetData(),
+	}
+	if request.GetUpdatedAt() != nil {
+		entry.UpdatedAt = request.GetUpdatedAt().AsTime()
+	}
+
+	result, err := l.Models.Logs.Update(ctx, entry)
+	if err != nil {
+		return nil, entryStatus(err)
+	}
+
+	return &logs.UpdateLogResponse{
+		Id:        result.ID,
+		Modified:  result.Modified,
+		UpdatedAt: timestamppb.New(result.UpdatedAt),
+	}, nil
+}
+
+func (l *LogServer) DeleteLog(ctx context.Context, request *logs.DeleteLogRequest) (*logs.DeleteLogsResponse, error) {
+	err := l.Models.Entries.Delete(ctx, request.GetId())
+	if err != nil {
+		return nil, entryStatus(err)
+	}
+
+	return &logs.DeleteLogsResponse{Count: 1}, nil
+}
+
+func (l *LogServer) DeleteLogs(ctx context.Context, request *logs.DeleteLogsRequest) (*logs.DeleteLogsResponse, error) {
+	q := data.LogQuery{
+		Name:  request.GetName(),
+		Level: request.GetLevel(),
+	}
+	if request.GetFrom() != nil {
+		q.From = request.GetFrom().AsTime()
+	}
+	if request.GetTo() != nil {
+		q.To = request.GetTo().AsTime()
+	}
+
+	count, err := l.Models.Entries.DeleteMany(ctx, q, request.GetDryRun())
+	if err != nil {
+		return nil, entryStatus(err)
+	}
+
+	return &logs.DeleteLogsResponse{Count: count, DryRun: request.GetDryRun()}, nil
+}
+
+// entryStatus maps the data errors of the entry calls to gRPC codes, as
+// entryError does to HTTP statuses.
+func entryStatus(err error) error {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return status.Error(codes.NotFound, "log entry not found")
+	case errors.Is(err, data.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, data.ErrAuditImmutable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, data.ErrRedactionRejected), errors.Is(err, data.ErrFilterRequired):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return err
+}
+
+func toLogPage(page *data.LogPage) *logs.LogPage {
+	res := &logs.LogPage{
+		Page:     int32(page.Page),
+		PageSize: int32(page.PageSize),
+		Total:    page.Total,
+	}
+	for _, entry := range page.Entries {
+		res.Entries = append(res.Entries, toLogRecord(entry))
+	}
+
+	return res
+}
+
+func toLogRecord(entry *data.LogEntry) *logs.LogRecord {
+	return &logs.LogRecord{
+		Id:        entry.ID,
+		Name:      entry.Name,
+		Data:      entry.Data,
+		Level:     entry.Level,
+		CreatedAt: timestamppb.New(entry.CreatedAt),
+		UpdatedAt: timestamppb.New(entry.UpdatedAt),
+		Score:     entry.Score,
+	}
+}
+
+// grpcServerOptions builds the interceptor chain, outermost first: panic
+// recovery, metrics and request logging, deadlines, token authentication
+// and tenant scoping. LOG_GRPC_TOKENS is a comma separated list of accepted
+// bearer tokens; without it authentication is off. LOG_GRPC_DEFAULT_TIMEOUT
+// (10s) applies to calls without a deadline and LOG_GRPC_MAX_TIMEOUT (1m)
+// caps longer ones. The server uses TLS unless plaintext was asked for.
+func (app *Config) grpcServerOptions() []grpc.ServerOption {
+	defaultTimeout, _ := time.ParseDuration(os.Getenv("LOG_GRPC_DEFAULT_TIMEOUT"))
+	if defaultTimeout <= 0 {
+		defaultTimeout = 10 * time.Second
+	}
+	maxTimeout, _ := time.ParseDuration(os.Getenv("LOG_GRPC_MAX_TIMEOUT"))
+	if maxTimeout <= 0 {
+		maxTimeout = time.Minute
+	}
+
+	unary := []grpc.UnaryServerInterceptor{recoverUnary, observeUnary, deadlineUnary(defaultTimeout, maxTimeout)}
+	stream := []grpc.StreamServerInterceptor{recoverStream, observeStream, deadlineStream(defaultTimeout, maxTimeout)}
+
+	var tokens []string
+	for _, token := range strings.Split(os.Getenv("LOG_GRPC_TOKENS"), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) > 0 {
+		unary = append(unary, authUnary(tokens))
+		stream = append(stream, authStream(tokens))
+	} else {
+		log.Println("gRPC token authentication is off; set LOG_GRPC_TOKENS to enable it")
+	}
+
+	unary = append(unary, app.tenantUnaryInterceptor)
+	stream = append(stream, app.tenantStreamInterceptor)
+
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if app.TLS != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(app.TLS.serverConfig("h2"))))
+	}
+
+	return options
+}
+
 func (app *Config) gRPCListen() {
 	lis, err := net.Listen("tcp", ":"+gRpcPort)
 	if err != nil {
 		log.Println("Failed to listen:", err)
 	}
 
-	server := grpc.NewServer()
+	server := grpc.NewServer(app.grpcServerOptions()...)
 	logs.RegisterLoggerServer(server, &LogServer{Models: app.Models})
 
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(logs.Logger_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
 	log.Println("Starting gRPC server on port", gRpcPort)
 
 	err = server.Serve(lis)
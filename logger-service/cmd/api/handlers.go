@@ -1,13 +1,16 @@
 package main
 
 import (
+	"errors"
 	"log-service/data"
 	"net/http"
+	"strconv"
 )
 
 type JSONPayload struct {
-	Name string `json:"name"`
-	Data string `json:"data"`
+	Name  string `json:"name"`
+	Data  string `json:"data"`
+	Level string `json:"level,omitempty"`
 }
 
 func (app *Config) WriteLog(w http.ResponseWriter, r *http.Request) {
@@ -15,11 +18,12 @@ func (app *Config) WriteLog(w http.ResponseWriter, r *http.Request) {
 	_ = app.readJSON(w, r, &requestPayload)
 
 	event := data.LogEntry{
-		Name: requestPayload.Name,
-		Data: requestPayload.Data,
+		Name:  requestPayload.Name,
+		Data:  requestPayload.Data,
+		Level: requestPayload.Level,
 	}
 
-	err := app.Models.LogEntry.Insert(event)
+	err := app.Models.Logs.Insert(r.Context(), event)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -32,3 +36,96 @@ func (app *Config) WriteLog(w http.ResponseWriter, r *http.Request) {
 
 	app.writeJSON(w, http.StatusAccepted, resp)
 }
+
+func (app *Config) GetLogs(w http.ResponseWriter, r *http.Request) {
+	q, err := app.readLogQuery(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	page, err := app.Models.Logs.Find(r.Context(), q)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Log entries",
+		Data:    page,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// SearchLogs runs a full-text search. q takes Mongo text search syntax and
+// phrase matches an exact phrase; either may be combined with the usual
+// name, time-range and pagination parameters.
+func (app *Config) SearchLogs(w http.ResponseWriter, r *http.Request) {
+	text := r.URL.Query().Get("q")
+	phrase := r.URL.Query().Get("phrase")
+	if text == "" && phrase == "" {
+		app.errorJSON(w, errors.New("q or phrase is required"))
+		return
+	}
+
+	q, err := app.readLogQuery(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	page, err := app.Models.Logs.Search(r.Context(), text, phrase, q)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Search results",
+		Data:    page,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetStats aggregates counts per time bucket, the top names and error rates.
+// It takes bucket (minute, hour or day), top and the usual query filters.
+func (app *Config) GetStats(w http.ResponseWriter, r *http.Request) {
+	q, err := app.readLogQuery(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	statsQuery := data.StatsQuery{
+		LogQuery: q,
+		Bucket:   r.URL.Query().Get("bucket"),
+	}
+	if v := r.URL.Query().Get("top"); v != "" {
+		if statsQuery.Top, err = strconv.Atoi(v); err != nil {
+			app.errorJSON(w, errors.New("invalid top"))
+			return
+		}
+	}
+
+	stats, err := app.Models.Logs.Stats(r.Context(), statsQuery)
+	if errors.Is(err, data.ErrInvalidBucket) {
+		app.errorJSON(w, err)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Log stats",
+		Data:    stats,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
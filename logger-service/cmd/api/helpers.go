@@ -3,8 +3,12 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log-service/data"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type jsonResponse struct {
@@ -64,3 +68,38 @@ func (app *Config) errorJSON(w http.ResponseWriter, err error, status ...int) er
 
 	return app.writeJSON(w, statusCode, payload)
 }
+
+// readLogQuery parses the filter and pagination parameters shared by the log
+// query endpoints: name, level, from, to (RFC 3339), page and page_size.
+func (app *Config) readLogQuery(r *http.Request) (data.LogQuery, error) {
+	values := r.URL.Query()
+
+	q := data.LogQuery{
+		Name:  values.Get("name"),
+		Level: values.Get("level"),
+	}
+
+	var err error
+	if v := values.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if v := values.Get("page"); v != "" {
+		if q.Page, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid page: %w", err)
+		}
+	}
+	if v := values.Get("page_size"); v != "" {
+		if q.PageSize, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid page_size: %w", err)
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log-service/data"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type IngestPayload struct {
+	DedupSeconds int `json:"dedup_seconds"`
+	// DebugSampleRate defaults to 1, keeping every DEBUG entry.
+	DebugSampleRate  *float64           `json:"debug_sample_rate"`
+	DebugSampleRates map[string]float64 `json:"debug_sample_rates"`
+}
+
+// readIngestConfig reads LOG_DEDUP_SECONDS, LOG_DEBUG_SAMPLE_RATE and
+// LOG_DEBUG_SAMPLE_RATES, a comma separated list of name=rate pairs. They
+// only seed the config; PUT /admin/ingest changes it at runtime.
+func readIngestConfig() (data.IngestConfig, error) {
+	config := data.IngestConfig{DebugSampleRate: 1}
+
+	if value := os.Getenv("LOG_DEDUP_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("LOG_DEDUP_SECONDS: %w", err)
+		}
+		config.DedupSeconds = seconds
+	}
+
+	if value := os.Getenv("LOG_DEBUG_SAMPLE_RATE"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return config, fmt.Errorf("LOG_DEBUG_SAMPLE_RATE: %w", err)
+		}
+		config.DebugSampleRate = rate
+	}
+
+	if value := os.Getenv("LOG_DEBUG_SAMPLE_RATES"); value != "" {
+		config.DebugSampleRates = make(map[string]float64)
+		for _, pair := range strings.Split(value, ",") {
+			name, rateValue, found := strings.Cut(strings.TrimSpace(pair), "=")
+			rate, err := strconv.ParseFloat(rateValue, 64)
+			if !found || name == "" || err != nil {
+				return config, fmt.Errorf("LOG_DEBUG_SAMPLE_RATES: invalid pair %q", pair)
+			}
+			config.DebugSampleRates[name] = rate
+		}
+	}
+
+	return config, nil
+}
+
+func (app *Config) IngestConfig(w http.ResponseWriter, r *http.Request) {
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Ingestion config",
+		Data:    app.Ingest.Config(),
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// SetIngestConfig replaces the deduplication window and sample rates.
+func (app *Config) SetIngestConfig(w http.ResponseWriter, r *http.Request) {
+	var requestPayload IngestPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	config := data.IngestConfig{
+		DedupSeconds:     requestPayload.DedupSeconds,
+		DebugSampleRate:  1,
+		DebugSampleRates: requestPayload.DebugSampleRates,
+	}
+	if requestPayload.DebugSampleRate != nil {
+		config.DebugSampleRate = *requestPayload.DebugSampleRate
+	}
+
+	if err := app.Ingest.SetConfig(config); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Ingestion config saved",
+		Data:    config,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
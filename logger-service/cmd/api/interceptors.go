@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"expvar"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+var (
+	grpcRequests = expvar.NewMap("grpc_requests")
+	grpcErrors   = expvar.NewMap("grpc_errors")
+	grpcLatency  = expvar.NewMap("grpc_latency_ms")
+	grpcInFlight = expvar.NewInt("grpc_in_flight")
+	grpcPanics   = expvar.NewInt("grpc_panics")
+)
+
+// publicMethod reports whether method may be called without a token: the
+// health and reflection services, so operators can probe the server.
+func publicMethod(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.Health/") ||
+		strings.HasPrefix(method, "/grpc.reflection.")
+}
+
+// recoverUnary turns a panic in a handler into an Internal error instead of
+// crashing the logger.
+func recoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			grpcPanics.Add(1)
+			log.Printf("Panic in gRPC %s: %v\n%s", info.FullMethod, r, debug.Stack())
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func recoverStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			grpcPanics.Add(1)
+			log.Printf("Panic in gRPC %s: %v\n%s", info.FullMethod, r, debug.Stack())
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+
+	return handler(srv, stream)
+}
+
+// observe records the metrics and the log line of a finished call.
+func observe(method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	code := status.Code(err)
+
+	grpcRequests.Add(method, 1)
+	grpcLatency.AddFloat(method, float64(elapsed.Microseconds())/1000)
+	if err != nil {
+		grpcErrors.Add(method+" "+code.String(), 1)
+	}
+
+	log.Printf("gRPC %s %s %s", method, code, elapsed)
+}
+
+// observeUnary logs every call and keeps per-method request, error and
+// latency counters under /debug/vars.
+func observeUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	grpcInFlight.Add(1)
+	defer grpcInFlight.Add(-1)
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observe(info.FullMethod, start, err)
+
+	return resp, err
+}
+
+func observeStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	grpcInFlight.Add(1)
+	defer grpcInFlight.Add(-1)
+
+	start := time.Now()
+	err := handler(srv, stream)
+	observe(info.FullMethod, start, err)
+
+	return err
+}
+
+// deadline gives calls without a deadline the default timeout and caps
+// longer deadlines at max.
+func deadline(ctx context.Context, defaultTimeout, max time.Duration) (context.Context, context.CancelFunc) {
+	if d, ok := ctx.Deadline(); ok && time.Until(d) <= max {
+		return context.WithCancel(ctx)
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithTimeout(ctx, max)
+	}
+	return context.WithTimeout(ctx, defaultTimeout)
+}
+
+func deadlineUnary(defaultTimeout, max time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := deadline(ctx, defaultTimeout, max)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+func deadlineStream(defaultTimeout, max time.Duration) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := deadline(stream.Context(), defaultTimeout, max)
+		defer cancel()
+
+		return handler(srv, &scopedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// checkToken accepts "authorization: Bearer <token>" metadata matching one
+// of tokens.
+func checkToken(ctx context.Context, method string, tokens []string) error {
+	if publicMethod(method) {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if !ok {
+			continue
+		}
+		for _, allowed := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+				return nil
+			}
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+func authUnary(tokens []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkToken(ctx, info.FullMethod, tokens); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func authStream(tokens []string) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkToken(stream.Context(), info.FullMethod, tokens); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
@@ -9,6 +13,11 @@ import (
 	"net"
 	"net/http"
 	"net/rpc"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -17,50 +26,277 @@ const (
 	rpcPort  = "5001"
 	mongoURL = "mongodb://mongo:27017"
 	gRpcPort = "50001"
+	// syslogPort serves both UDP and TCP.
+	syslogPort = "514"
 )
 
-var client *mongo.Client
-
 type Config struct {
 	Models data.Models
+	Alerts *data.Alerter
+	Ingest *data.Ingest
+	// Audit is nil unless LOG_AUDIT is set.
+	Audit *data.Auditor
+	// RequireTenant rejects writes and queries without a tenant id instead
+	// of using data.DefaultTenant.
+	RequireTenant bool
+	// Archive is nil unless LOG_ARCHIVE_DIR is set.
+	Archive          *data.Archiver
+	ArchiveAfterDays int
+	// TLS is nil when the RPC and gRPC listeners serve plaintext.
+	TLS *certReloader
+	// SyslogTenant receives every syslog message; syslog carries no tenant
+	// id of its own.
+	SyslogTenant string
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := restoreCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	mongoClient, err := connectToMongo()
+	store, closeStore, err := openStore(os.Getenv("LOG_STORE"))
 	if err != nil {
 		log.Panic(err)
 	}
-	client = mongoClient
+	defer closeStore()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+	var auditor *data.Auditor
+	if audit, _ := strconv.ParseBool(os.Getenv("LOG_AUDIT")); audit {
+		auditor = data.NewAuditor(store)
+		store = auditor
+		log.Println("Audit mode on: log entries are hash-chained and immutable")
+	}
 
-	defer func() {
-		if err = client.Disconnect(ctx); err != nil {
-			log.Panic(err)
-		}
-	}()
+	if size, _ := strconv.Atoi(os.Getenv("LOG_BUFFER_SIZE")); size > 0 {
+		capacity, _ := strconv.Atoi(os.Getenv("LOG_BUFFER_CAPACITY"))
+		interval, _ := time.ParseDuration(os.Getenv("LOG_BUFFER_FLUSH_INTERVAL"))
+		bufferedStore := data.NewBufferedStore(store, data.BufferConfig{
+			BatchSize:     size,
+			FlushInterval: interval,
+			Capacity:      capacity,
+		})
+		defer bufferedStore.Close()
+		store = bufferedStore
+		log.Println("Buffering log writes in batches of", size)
+	}
+
+	// Archiving reads, deletes and restores below the alerting and
+	// redaction layers: archived entries were redacted on the way in and
+	// restoring them should not fire alerts.
+	archiveStore := store
+
+	alerter := data.NewAlerter(store, sendAlertMail)
+	if err = alerter.Reload(context.Background()); err != nil {
+		log.Println("Error loading alert rules", err)
+	}
+
+	redactionConfig, err := readRedactionConfig()
+	if err != nil {
+		log.Panic(err)
+	}
+	redactor, err := data.NewRedactor(alerter, redactionConfig)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ingestConfig, err := readIngestConfig()
+	if err != nil {
+		log.Panic(err)
+	}
+	ingest, err := data.NewIngest(redactor, ingestConfig)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer ingest.Close()
 
 	app := Config{
-		Models: data.New(client),
+		Models: data.New(ingest),
+		Alerts: alerter,
+		Audit:  auditor,
+		Ingest: ingest,
+	}
+	app.RequireTenant, _ = strconv.ParseBool(os.Getenv("LOG_REQUIRE_TENANT"))
+
+	app.TLS, err = newCertReloader()
+	if err != nil {
+		log.Panic(err)
+	}
+	if app.TLS == nil {
+		log.Println("TLS is off: RPC and gRPC are served in plaintext")
+	}
+
+	if days, _ := strconv.Atoi(os.Getenv("LOG_RETENTION_DAYS")); days > 0 {
+		app.Models.Retention.DefaultDays = days
+		if err = app.Models.Retention.SeedGlobal(context.Background(), days); err != nil {
+			log.Println("Error seeding global retention policy", err)
+		}
 	}
 
-	err = rpc.Register(new(RPCServer))
+	retentionInterval, _ := time.ParseDuration(os.Getenv("LOG_RETENTION_INTERVAL"))
+	if retentionInterval <= 0 {
+		retentionInterval = time.Hour
+	}
+	if auditor == nil {
+		go app.retentionJob(retentionInterval)
+	}
+
+	if dir := os.Getenv("LOG_ARCHIVE_DIR"); dir != "" {
+		if auditor != nil {
+			log.Println("Archiving is off in audit mode")
+		} else {
+			app.Archive, err = data.NewArchiver(archiveStore, dir)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			app.ArchiveAfterDays, _ = strconv.Atoi(os.Getenv("LOG_ARCHIVE_AFTER_DAYS"))
+			if app.ArchiveAfterDays <= 0 {
+				app.ArchiveAfterDays = 30
+			}
+			archiveInterval, _ := time.ParseDuration(os.Getenv("LOG_ARCHIVE_INTERVAL"))
+			if archiveInterval <= 0 {
+				archiveInterval = 24 * time.Hour
+			}
+			go app.archiveJob(archiveInterval)
+			log.Println("Archiving log entries older than", app.ArchiveAfterDays, "days to", dir)
+		}
+	}
+
+	err = rpc.Register(&RPCServer{Models: app.Models, app: &app})
 	go app.rpcListen()
 	go app.gRPCListen()
 
+	// With LOG_REQUIRE_TENANT set and no LOG_SYSLOG_TENANT, syslog stays
+	// off rather than writing to the default tenant.
+	app.SyslogTenant, err = app.resolveTenant(os.Getenv("LOG_SYSLOG_TENANT"))
+	if err != nil {
+		log.Println("Syslog is off: LOG_SYSLOG_TENANT", err)
+	} else {
+		go app.syslogListenUDP()
+		go app.syslogListenTCP()
+	}
+
 	srv := http.Server{
 		Addr:    ":" + webPort,
 		Handler: app.routes(),
 	}
 
+	go shutdownOnSignal(&srv)
+
 	err = srv.ListenAndServe()
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Panic(err)
 	}
 }
 
+// shutdownOnSignal stops the web server on SIGINT or SIGTERM so the deferred
+// cleanup in main (buffer flush, closing the store) gets to run.
+func shutdownOnSignal(srv *http.Server) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down logger service")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Error shutting down web server", err)
+	}
+}
+
+// openStore opens the log store named by kind: mongo (the default), file or
+// memory. The returned func releases it on shutdown.
+func openStore(kind string) (data.LogStore, func(), error) {
+	switch kind {
+	case "", "mongo":
+		mongoClient, err := connectToMongo()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		store := data.NewMongoStore(mongoClient)
+		if err := store.EnsureIndexes(context.Background()); err != nil {
+			log.Println("Continuing without log indexes", err)
+		}
+
+		closeStore := func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			if err := mongoClient.Disconnect(ctx); err != nil {
+				log.Panic(err)
+			}
+		}
+		return store, closeStore, nil
+
+	case "file":
+		path := os.Getenv("LOG_FILE_PATH")
+		if path == "" {
+			path = "logs.ndjson"
+		}
+
+		store, err := data.NewFileStore(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Println("Storing logs in", path)
+
+		closeStore := func() {
+			if err := store.Close(); err != nil {
+				log.Println("Error closing log file store", err)
+			}
+		}
+		return store, closeStore, nil
+
+	case "memory":
+		log.Println("Storing logs in memory")
+		return data.NewMemoryStore(), func() {}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown LOG_STORE %q", kind)
+	}
+}
+
+// readRedactionConfig reads LOG_REDACT (comma separated built-in detectors,
+// all of them when unset, "none" for none), LOG_REDACT_MODE (mask, hash or
+// drop), LOG_REDACT_RULES (a JSON file of custom rules) and
+// LOG_REDACT_HASH_KEY.
+func readRedactionConfig() (data.RedactionConfig, error) {
+	config := data.RedactionConfig{
+		Detectors: data.BuiltinDetectors(),
+		Mode:      data.RedactionMode(os.Getenv("LOG_REDACT_MODE")),
+		HashKey:   os.Getenv("LOG_REDACT_HASH_KEY"),
+	}
+
+	switch detectors := os.Getenv("LOG_REDACT"); detectors {
+	case "":
+	case "none":
+		config.Detectors = nil
+	default:
+		config.Detectors = strings.Split(detectors, ",")
+		for i := range config.Detectors {
+			config.Detectors[i] = strings.TrimSpace(config.Detectors[i])
+		}
+	}
+
+	if path := os.Getenv("LOG_REDACT_RULES"); path != "" {
+		rules, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(rules, &config.Rules); err != nil {
+			return config, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	return config, nil
+}
+
 func connectToMongo() (*mongo.Client, error) {
 	clientOptions := options.Client().ApplyURI(mongoURL)
 	clientOptions.SetAuth(options.Credential{
@@ -86,6 +322,10 @@ func (app *Config) rpcListen() error {
 
 	defer listen.Close()
 
+	if app.TLS != nil {
+		listen = tls.NewListener(listen, app.TLS.serverConfig())
+	}
+
 	for {
 		conn, err := listen.Accept()
 		if err != nil {
@@ -93,6 +333,6 @@ func (app *Config) rpcListen() error {
 			return err
 		}
 
-		go rpc.ServeConn(conn)
+		go serveRPCConn(conn)
 	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"log-service/data"
+	"net/http"
+	"time"
+)
+
+type RetentionPayload struct {
+	Name string `json:"name"`
+	Days int    `json:"days"`
+}
+
+// retentionJob enforces the retention policies every interval until the
+// process exits.
+func (app *Config) retentionJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		enforced, err := app.Models.Retention.EnforceAll(context.Background())
+		if err != nil {
+			log.Println("Error enforcing retention policies", err)
+		}
+		for tenant, policies := range enforced {
+			for _, policy := range policies {
+				if policy.LastRemoved > 0 {
+					log.Printf("Retention policy %s of tenant %s removed %d log entries", policy.Name, tenant, policy.LastRemoved)
+				}
+			}
+		}
+	}
+}
+
+func (app *Config) RetentionPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := app.Models.Retention.All(r.Context())
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Retention policies",
+		Data:    policies,
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+func (app *Config) SetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	var requestPayload RetentionPayload
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if name := chi.URLParam(r, "name"); name != "" {
+		requestPayload.Name = name
+	}
+
+	err = app.Models.Retention.Upsert(r.Context(), data.RetentionPolicy{
+		Name: requestPayload.Name,
+		Days: requestPayload.Days,
+	})
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Retention policy saved",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+func (app *Config) DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	err := app.Models.Retention.Delete(r.Context(), chi.URLParam(r, "name"))
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, errors.New("retention policy not found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Retention policy deleted",
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
+
+// EnforceRetention runs the purge of the caller's tenant immediately and
+// reports how many entries each of its policies removed.
+func (app *Config) EnforceRetention(w http.ResponseWriter, r *http.Request) {
+	policies, err := app.Models.Retention.Enforce(r.Context())
+	if errors.Is(err, data.ErrAuditImmutable) {
+		app.errorJSON(w, err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resp := jsonResponse{
+		Error:   false,
+		Message: "Retention policies enforced",
+		Data:    policies,
+	}
+
+	app.writeJSON(w, http.StatusAccepted, resp)
+}
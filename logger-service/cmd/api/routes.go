@@ -1,6 +1,7 @@
 package main
 
 import (
+	"expvar"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -13,15 +14,57 @@ func (app *Config) routes() http.Handler {
 	mux.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", tenantHeader},
+		ExposedHeaders:   []string{"Link", exportCursorHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	mux.Use(middleware.Heartbeat("/ping"))
+	mux.Use(app.tenantScope)
 
 	mux.Post("/log", app.WriteLog)
+	mux.Get("/logs", app.GetLogs)
+	mux.Get("/logs/search", app.SearchLogs)
+	mux.Get("/logs/stats", app.GetStats)
+	mux.Get("/logs/export", app.ExportLogs)
+
+	mux.Route("/admin/logs", func(mux chi.Router) {
+		mux.Delete("/", app.DeleteLogEntries)
+		mux.Get("/{id}", app.GetLogEntry)
+		mux.Put("/{id}", app.UpdateLogEntry)
+		mux.Delete("/{id}", app.DeleteLogEntry)
+	})
+
+	mux.Route("/admin/retention", func(mux chi.Router) {
+		mux.Get("/", app.RetentionPolicies)
+		mux.Put("/", app.SetRetentionPolicy)
+		mux.Put("/{name}", app.SetRetentionPolicy)
+		mux.Delete("/{name}", app.DeleteRetentionPolicy)
+		mux.Post("/enforce", app.EnforceRetention)
+	})
+
+	mux.Route("/admin/alerts", func(mux chi.Router) {
+		mux.Get("/", app.AlertRules)
+		mux.Post("/", app.SaveAlertRule)
+		mux.Get("/{id}", app.GetAlertRule)
+		mux.Put("/{id}", app.SaveAlertRule)
+		mux.Delete("/{id}", app.DeleteAlertRule)
+		mux.Post("/{id}/silence", app.SilenceAlertRule)
+	})
+
+	mux.Get("/admin/ingest", app.IngestConfig)
+	mux.Put("/admin/ingest", app.SetIngestConfig)
+
+	mux.Get("/admin/audit/verify", app.VerifyAudit)
+
+	mux.Route("/admin/archive", func(mux chi.Router) {
+		mux.Get("/", app.ArchiveManifest)
+		mux.Post("/run", app.RunArchive)
+		mux.Post("/restore", app.RestoreArchive)
+	})
+
+	mux.Handle("/debug/vars", expvar.Handler())
 
 	return mux
 }
@@ -1,25 +1,113 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"log-service/data"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
 	"time"
 )
 
-type RPCServer struct{}
+// RPCServer serves the logger over net/rpc, with gob or JSON-RPC 1.0 on the
+// same port. Every call takes the caller's tenant and, optionally, the
+// deadline after which the caller stops waiting; the logger gives up on the
+// call at the same time.
+type RPCServer struct {
+	Models data.Models
+	app    *Config
+}
 
 type RPCPayload struct {
-	Name string
-	Data string
+	Name     string
+	Data     string
+	Level    string
+	Tenant   string
+	Deadline time.Time
+}
+
+type RPCBatchPayload struct {
+	Entries  []RPCPayload
+	Tenant   string
+	Deadline time.Time
+}
+
+// RPCBatchResult reports the outcome of every entry by its position in the
+// request.
+type RPCBatchResult struct {
+	Inserted int
+	Failed   int
+	Results  []RPCItemResult
+}
+
+type RPCItemResult struct {
+	Index int
+	Ok    bool
+	Error string
+}
+
+// RPCQuery filters and pages like the HTTP API. With Text or Phrase set it
+// runs a full-text search instead.
+type RPCQuery struct {
+	Name     string
+	Level    string
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+	Text     string
+	Phrase   string
+	Tenant   string
+	Deadline time.Time
+}
+
+type RPCGetOne struct {
+	ID       string
+	Tenant   string
+	Deadline time.Time
+}
+
+// context scopes a call to tenant and to the caller's deadline.
+func (r *RPCServer) context(tenant string, deadline time.Time) (context.Context, context.CancelFunc, error) {
+	tenant, err := r.app.resolveTenant(tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := data.WithTenant(context.Background(), tenant)
+	if deadline.IsZero() {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+	if time.Until(deadline) <= 0 {
+		return nil, nil, context.DeadlineExceeded
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return ctx, cancel, nil
+}
+
+// Ping lets clients check a pooled connection without touching the store.
+func (r *RPCServer) Ping(_ string, resp *string) error {
+	*resp = "pong"
+	return nil
 }
 
 func (r *RPCServer) LogInfo(payload RPCPayload, resp *string) error {
-	collection := client.Database("logs").Collection("logs")
-	_, err := collection.InsertOne(context.TODO(), data.LogEntry{
-		Name:      payload.Name,
-		Data:      payload.Data,
-		CreatedAt: time.Now(),
+	ctx, cancel, err := r.context(payload.Tenant, payload.Deadline)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	err = r.Models.Logs.Insert(ctx, data.LogEntry{
+		Name:  payload.Name,
+		Data:  payload.Data,
+		Level: payload.Level,
 	})
 	if err != nil {
 		log.Println("error inserting log", err)
@@ -30,3 +118,136 @@ func (r *RPCServer) LogInfo(payload RPCPayload, resp *string) error {
 
 	return nil
 }
+
+// LogBatch inserts the valid entries in one InsertMany call. The tenant and
+// deadline of the batch apply; those of the entries are ignored.
+func (r *RPCServer) LogBatch(payload RPCBatchPayload, resp *RPCBatchResult) error {
+	ctx, cancel, err := r.context(payload.Tenant, payload.Deadline)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	resp.Results = make([]RPCItemResult, len(payload.Entries))
+
+	var batch []data.LogEntry
+	var positions []int
+	for i, entry := range payload.Entries {
+		resp.Results[i].Index = i
+		if entry.Name == "" {
+			resp.Results[i].Error = "log entry name is required"
+			continue
+		}
+		batch = append(batch, data.LogEntry{
+			Name:  entry.Name,
+			Data:  entry.Data,
+			Level: entry.Level,
+		})
+		positions = append(positions, i)
+	}
+
+	itemErrors, err := r.Models.Logs.InsertMany(ctx, batch)
+	if err != nil {
+		log.Println("error inserting logs", err)
+		return err
+	}
+
+	for i, itemErr := range itemErrors {
+		if itemErr != nil {
+			resp.Results[positions[i]].Error = itemErr.Error()
+			continue
+		}
+		resp.Results[positions[i]].Ok = true
+	}
+
+	for _, result := range resp.Results {
+		if result.Ok {
+			resp.Inserted++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	return nil
+}
+
+func (r *RPCServer) Query(query RPCQuery, resp *data.LogPage) error {
+	ctx, cancel, err := r.context(query.Tenant, query.Deadline)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	q := data.LogQuery{
+		Name:     query.Name,
+		Level:    query.Level,
+		From:     query.From,
+		To:       query.To,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+	}
+
+	var page *data.LogPage
+	if query.Text != "" || query.Phrase != "" {
+		page, err = r.Models.Logs.Search(ctx, query.Text, query.Phrase, q)
+	} else {
+		page, err = r.Models.Logs.Find(ctx, q)
+	}
+	if err != nil {
+		return err
+	}
+
+	*resp = *page
+	return nil
+}
+
+func (r *RPCServer) GetOne(request RPCGetOne, resp *data.LogEntry) error {
+	if request.ID == "" {
+		return errors.New("id is required")
+	}
+
+	ctx, cancel, err := r.context(request.Tenant, request.Deadline)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	entry, err := r.Models.Logs.GetOne(ctx, request.ID)
+	if errors.Is(err, data.ErrNotFound) {
+		return fmt.Errorf("log entry %s not found", request.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	*resp = *entry
+	return nil
+}
+
+// serveRPCConn picks the codec from the first byte the client sends: JSON-RPC
+// requests are objects, anything else is taken as gob.
+func serveRPCConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	conn = &peekedConn{Conn: conn, reader: reader}
+	if first[0] == '{' {
+		jsonrpc.ServeConn(conn)
+		return
+	}
+	rpc.ServeConn(conn)
+}
+
+// peekedConn reads through the buffer that sniffed the codec.
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
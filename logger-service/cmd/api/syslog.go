@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log-service/data"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSyslogMessage bounds a single syslog message; it also covers the
+// largest UDP datagram.
+const maxSyslogMessage = 64 * 1024
+
+// syslogName is stored when a message carries no app-name or tag.
+const syslogName = "syslog"
+
+var errInvalidSyslog = errors.New("invalid syslog message")
+
+// syslogLevels maps syslog severities 0-7 to our log levels.
+var syslogLevels = [8]string{"FATAL", "CRITICAL", "CRITICAL", "ERROR", "WARNING", "INFO", "INFO", "DEBUG"}
+
+var syslogFacilities = [24]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// syslogListenUDP reads one syslog message per datagram.
+func (app *Config) syslogListenUDP() error {
+	log.Println("Starting syslog UDP listener on port", syslogPort)
+	conn, err := net.ListenPacket("udp", "0.0.0.0:"+syslogPort)
+	if err != nil {
+		log.Println("Error starting syslog UDP listener", err)
+		return err
+	}
+
+	defer conn.Close()
+
+	buf := make([]byte, maxSyslogMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Println("Error reading syslog datagram", err)
+			return err
+		}
+
+		app.storeSyslog(buf[:n], addr)
+	}
+}
+
+func (app *Config) syslogListenTCP() error {
+	log.Println("Starting syslog TCP listener on port", syslogPort)
+	listen, err := net.Listen("tcp", "0.0.0.0:"+syslogPort)
+	if err != nil {
+		log.Println("Error starting syslog TCP listener", err)
+		return err
+	}
+
+	defer listen.Close()
+
+	for {
+		conn, err := listen.Accept()
+		if err != nil {
+			log.Println("Error accepting connection", err)
+			return err
+		}
+
+		go app.serveSyslogConn(conn)
+	}
+}
+
+// serveSyslogConn reads messages framed either by octet counting or by
+// newlines (RFC 6587). The framing is detected per message.
+func (app *Config) serveSyslogConn(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReaderSize(conn, maxSyslogMessage)
+	for {
+		msg, err := readSyslogFrame(reader)
+		if len(msg) > 0 {
+			app.storeSyslog(msg, conn.RemoteAddr())
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Println("Error reading syslog stream from", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func readSyslogFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := reader.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil || length > maxSyslogMessage {
+			return nil, fmt.Errorf("invalid syslog frame length %q", prefix)
+		}
+
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(reader, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	msg, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("syslog message longer than %d bytes", maxSyslogMessage)
+	}
+	return bytes.Clone(msg), err
+}
+
+// storeSyslog parses msg and inserts it into app.SyslogTenant, falling back
+// to the sender's address when the message has no hostname.
+func (app *Config) storeSyslog(msg []byte, from net.Addr) {
+	entry, err := parseSyslog(msg, time.Now())
+	if err != nil {
+		log.Println("Error parsing syslog message from", from, err)
+		return
+	}
+
+	if entry.Host == "" && from != nil {
+		entry.Host = from.String()
+		if host, _, err := net.SplitHostPort(entry.Host); err == nil {
+			entry.Host = host
+		}
+	}
+
+	err = app.Models.Logs.Insert(data.WithTenant(context.Background(), app.SyslogTenant), entry)
+	if err != nil {
+		log.Println("error inserting log", err)
+	}
+}
+
+// parseSyslog accepts RFC 5424 messages and, for anything without the
+// version 1 header, RFC 3164 messages. now resolves the missing year of
+// RFC 3164 timestamps.
+func parseSyslog(msg []byte, now time.Time) (data.LogEntry, error) {
+	line := strings.TrimRight(string(msg), "\r\n\x00")
+
+	if !strings.HasPrefix(line, "<") {
+		return data.LogEntry{}, errInvalidSyslog
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return data.LogEntry{}, errInvalidSyslog
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return data.LogEntry{}, errInvalidSyslog
+	}
+
+	entry := data.LogEntry{
+		Name:     syslogName,
+		Level:    syslogLevels[pri%8],
+		Facility: syslogFacilities[pri/8],
+	}
+
+	rest := line[end+1:]
+	if strings.HasPrefix(rest, "1 ") {
+		err = parseRFC5424(rest[2:], &entry)
+	} else {
+		parseRFC3164(rest, now, &entry)
+	}
+
+	return entry, err
+}
+
+// parseRFC5424 parses TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG. The
+// structured data, when present, is kept in front of the message.
+func parseRFC5424(rest string, entry *data.LogEntry) error {
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		return errInvalidSyslog
+	}
+	timestamp, hostname, appName := fields[0], fields[1], fields[2]
+
+	if timestamp != "-" {
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return fmt.Errorf("invalid syslog timestamp %q", timestamp)
+		}
+		entry.CreatedAt = t
+	}
+	if hostname != "-" {
+		entry.Host = hostname
+	}
+	if appName != "-" {
+		entry.Name = appName
+	}
+
+	sd, message, err := splitStructuredData(fields[5])
+	if err != nil {
+		return err
+	}
+	message = strings.TrimPrefix(message, "\ufeff")
+
+	if sd != "-" {
+		message = strings.TrimSpace(sd + " " + message)
+	}
+	entry.Data = message
+
+	return nil
+}
+
+// splitStructuredData splits "-" or a run of [id param="value"] elements off
+// the front of s. Quoted values may contain escaped quotes and brackets.
+func splitStructuredData(s string) (string, string, error) {
+	if strings.HasPrefix(s, "-") {
+		return "-", strings.TrimPrefix(s[1:], " "), nil
+	}
+
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		inQuote := false
+		for i++; i < len(s); i++ {
+			c := s[i]
+			if inQuote && c == '\\' {
+				i++
+				continue
+			}
+			if c == '"' {
+				inQuote = !inQuote
+			}
+			if c == ']' && !inQuote {
+				i++
+				break
+			}
+		}
+	}
+	if i == 0 || s[i-1] != ']' {
+		return "", "", errors.New("invalid syslog structured data")
+	}
+
+	return s[:i], strings.TrimPrefix(s[i:], " "), nil
+}
+
+// parseRFC3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG". It never
+// fails: as RFC 3164 asks of relays, anything it cannot make sense of is
+// kept as the message.
+func parseRFC3164(rest string, now time.Time, entry *data.LogEntry) {
+	if len(rest) < len(time.Stamp) {
+		entry.Data = rest
+		return
+	}
+
+	t, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], now.Location())
+	if err != nil {
+		entry.Data = rest
+		return
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	// A December message read in January belongs to last year.
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	entry.CreatedAt = t
+
+	rest = strings.TrimPrefix(rest[len(time.Stamp):], " ")
+
+	// Some senders leave out the hostname and go straight to the tag.
+	if host, after, found := strings.Cut(rest, " "); found && !isSyslogTag(host) {
+		entry.Host = host
+		rest = after
+	}
+
+	if isSyslogTag(firstField(rest)) {
+		tag, message, _ := strings.Cut(rest, ":")
+		if i := strings.IndexByte(tag, '['); i >= 0 {
+			tag = tag[:i]
+		}
+		entry.Name = tag
+		rest = strings.TrimPrefix(message, " ")
+	}
+
+	entry.Data = rest
+}
+
+func firstField(s string) string {
+	field, _, _ := strings.Cut(s, " ")
+	return field
+}
+
+// isSyslogTag reports whether field looks like "tag:" or "tag[pid]:".
+func isSyslogTag(field string) bool {
+	if !strings.HasSuffix(field, ":") || len(field) < 2 {
+		return false
+	}
+	tag := strings.TrimSuffix(field, ":")
+	if i := strings.IndexByte(tag, '['); i >= 0 {
+		if !strings.HasSuffix(tag, "]") {
+			return false
+		}
+		tag = tag[:i]
+	}
+	return tag != "" && !strings.ContainsAny(tag, "[]")
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log-service/data"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	now := time.Date(2026, time.March, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		msg  string
+		want data.LogEntry
+	}{
+		{
+			name: "rfc5424",
+			msg:  "<34>1 2026-03-05T11:59:58.123Z web01 sshd 4321 ID47 - Failed password for root",
+			want: data.LogEntry{
+				Name:      "sshd",
+				Data:      "Failed password for root",
+				Level:     "CRITICAL",
+				Host:      "web01",
+				Facility:  "auth",
+				CreatedAt: time.Date(2026, time.March, 5, 11, 59, 58, 123000000, time.UTC),
+			},
+		},
+		{
+			name: "rfc5424 nil fields",
+			msg:  "<13>1 - - - - - - hello",
+			want: data.LogEntry{Name: syslogName, Data: "hello", Level: "INFO", Facility: "user"},
+		},
+		{
+			name: "rfc5424 structured data kept before the message",
+			msg:  `<165>1 2026-03-05T12:00:00Z host app - - [meta a="1" b="x\"]y"][id2 c="2"] body`,
+			want: data.LogEntry{
+				Name:      "app",
+				Data:      `[meta a="1" b="x\"]y"][id2 c="2"] body`,
+				Level:     "INFO",
+				Host:      "host",
+				Facility:  "local4",
+				CreatedAt: time.Date(2026, time.March, 5, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "rfc5424 byte order mark",
+			msg:  "<14>1 - host app - - - \ufeffmessage\r\n",
+			want: data.LogEntry{Name: "app", Data: "message", Level: "INFO", Host: "host", Facility: "user"},
+		},
+		{
+			name: "rfc3164 with host and tag",
+			msg:  "<11>Mar  5 11:00:00 mybox cron[123]: job done",
+			want: data.LogEntry{
+				Name:      "cron",
+				Data:      "job done",
+				Level:     "ERROR",
+				Host:      "mybox",
+				Facility:  "user",
+				CreatedAt: time.Date(2026, time.March, 5, 11, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "rfc3164 without host",
+			msg:  "<0>Mar  5 11:00:00 kernel: panic",
+			want: data.LogEntry{
+				Name:      "kernel",
+				Data:      "panic",
+				Level:     "FATAL",
+				Facility:  "kern",
+				CreatedAt: time.Date(2026, time.March, 5, 11, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "rfc3164 december read in january",
+			msg:  "<15>Dec 31 23:59:59 host app: late",
+			want: data.LogEntry{
+				Name:      "app",
+				Data:      "late",
+				Level:     "DEBUG",
+				Host:      "host",
+				Facility:  "user",
+				CreatedAt: time.Date(2025, time.December, 31, 23, 59, 59, 0, time.UTC),
+			},
+		},
+		{
+			name: "rfc3164 without timestamp keeps everything",
+			msg:  "<191>just some text",
+			want: data.LogEntry{Name: syslogName, Data: "just some text", Level: "DEBUG", Facility: "local7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := now
+			if strings.Contains(tt.name, "january") {
+				at = time.Date(2026, time.January, 1, 0, 5, 0, 0, time.UTC)
+			}
+
+			got, err := parseSyslog([]byte(tt.msg), at)
+			if err != nil {
+				t.Fatalf("parseSyslog(%q) returned %v", tt.msg, err)
+			}
+			if !got.CreatedAt.Equal(tt.want.CreatedAt) {
+				t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, tt.want.CreatedAt)
+			}
+			got.CreatedAt, tt.want.CreatedAt = time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("parseSyslog(%q) = %+v, want %+v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyslogInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"no priority",
+		"<>1 - - - - - -",
+		"<192>too high",
+		"<-1>negative",
+		"<12345>too long",
+		"<13>1 not-a-time host app - - - msg",
+		"<13>1 - host app - -",
+		"<13>1 - host app - - [unterminated msg",
+	}
+
+	for _, msg := range tests {
+		if entry, err := parseSyslog([]byte(msg), time.Now()); err == nil {
+			t.Errorf("parseSyslog(%q) = %+v, want an error", msg, entry)
+		}
+	}
+}
+
+func TestSyslogLevelsAndFacilities(t *testing.T) {
+	tests := []struct {
+		pri      string
+		level    string
+		facility string
+	}{
+		{"<0>", "FATAL", "kern"},
+		{"<9>", "CRITICAL", "user"},
+		{"<18>", "CRITICAL", "mail"},
+		{"<27>", "ERROR", "daemon"},
+		{"<36>", "WARNING", "auth"},
+		{"<45>", "INFO", "syslog"},
+		{"<86>", "INFO", "authpriv"},
+		{"<191>", "DEBUG", "local7"},
+	}
+
+	for _, tt := range tests {
+		entry, err := parseSyslog([]byte(tt.pri+"1 - - - - - - x"), time.Now())
+		if err != nil {
+			t.Fatalf("parseSyslog(%s) returned %v", tt.pri, err)
+		}
+		if entry.Level != tt.level || entry.Facility != tt.facility {
+			t.Errorf("%s: got %s/%s, want %s/%s", tt.pri, entry.Level, entry.Facility, tt.level, tt.facility)
+		}
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []string
+		err    bool
+	}{
+		{
+			name:   "octet counting",
+			stream: "11 <13>1 - - x5 <13>y",
+			want:   []string{"<13>1 - - x", "<13>y"},
+		},
+		{
+			name:   "octet counted message with a newline",
+			stream: "8 <13>a\nb\n",
+			want:   []string{"<13>a\nb\n"},
+		},
+		{
+			name:   "newline framing",
+			stream: "<13>first\n<13>second\n",
+			want:   []string{"<13>first\n", "<13>second\n"},
+		},
+		{
+			name:   "mixed framing",
+			stream: "5 <13>a<13>b\n",
+			want:   []string{"<13>a", "<13>b\n"},
+		},
+		{
+			name:   "last line without newline",
+			stream: "<13>first\n<13>tail",
+			want:   []string{"<13>first\n", "<13>tail"},
+		},
+		{
+			name:   "length over the limit",
+			stream: "99999999 <13>x",
+			err:    true,
+		},
+		{
+			name:   "truncated octet counted message",
+			stream: "20 <13>short",
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReaderSize(strings.NewReader(tt.stream), maxSyslogMessage)
+
+			var got []string
+			var err error
+			for {
+				var msg []byte
+				msg, err = readSyslogFrame(reader)
+				if len(msg) > 0 {
+					got = append(got, string(msg))
+				}
+				if err != nil {
+					break
+				}
+			}
+
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			if tt.err != (err != nil) {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("frames = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
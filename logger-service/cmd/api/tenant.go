@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log-service/data"
+	"net/http"
+	"strings"
+)
+
+// tenantHeader carries the tenant id on HTTP requests. gRPC callers send it
+// as metadata under the lower-cased key and net/rpc callers in the payload.
+const tenantHeader = "X-Tenant-ID"
+
+var tenantMetadataKey = strings.ToLower(tenantHeader)
+
+var errMissingTenant = errors.New("tenant id is required")
+
+// resolveTenant validates a tenant id sent by a caller. Without one the
+// write goes to data.DefaultTenant, unless LOG_REQUIRE_TENANT is set.
+func (app *Config) resolveTenant(tenant string) (string, error) {
+	tenant = strings.ToLower(strings.TrimSpace(tenant))
+	if tenant == "" {
+		if app.RequireTenant {
+			return "", errMissingTenant
+		}
+		return data.DefaultTenant, nil
+	}
+	if !data.ValidTenant(tenant) {
+		return "", data.ErrInvalidTenant
+	}
+	return tenant, nil
+}
+
+// tenantScope scopes the request context to the tenant in tenantHeader, so
+// every read and write the handlers make stays inside that tenant.
+func (app *Config) tenantScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := app.resolveTenant(r.Header.Get(tenantHeader))
+		if err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(data.WithTenant(r.Context(), tenant)))
+	})
+}
+
+func (app *Config) grpcTenant(ctx context.Context) (context.Context, error) {
+	var tenant string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(tenantMetadataKey); len(values) > 0 {
+			tenant = values[0]
+		}
+	}
+
+	tenant, err := app.resolveTenant(tenant)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return data.WithTenant(ctx, tenant), nil
+}
+
+func (app *Config) tenantUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if publicMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	ctx, err := app.grpcTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (app *Config) tenantStreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if publicMethod(info.FullMethod) {
+		return handler(srv, stream)
+	}
+
+	ctx, err := app.grpcTenant(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &scopedStream{ServerStream: stream, ctx: ctx})
+}
+
+// scopedStream replaces the context of a server stream.
+type scopedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *scopedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often a handshake may look at the certificate
+// files for changes.
+const certCheckInterval = 10 * time.Second
+
+var errPlaintext = errors.New("LOG_TLS_CERT and LOG_TLS_KEY are not set; set LOG_TLS_INSECURE=true to serve RPC and gRPC in plaintext")
+
+// certReloader keeps the server certificate and client CA pool in memory and
+// reloads them when the files change, so rotated certificates are picked up
+// without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.Mutex
+	config    *tls.Config
+	modified  time.Time
+	lastCheck time.Time
+}
+
+// newCertReloader loads LOG_TLS_CERT and LOG_TLS_KEY for the RPC and gRPC
+// listeners. When LOG_TLS_CLIENT_CA is set, clients must present a
+// certificate signed by it. It returns nil only when plaintext was asked for
+// with LOG_TLS_INSECURE.
+func newCertReloader() (*certReloader, error) {
+	certFile, keyFile := os.Getenv("LOG_TLS_CERT"), os.Getenv("LOG_TLS_KEY")
+	if certFile == "" && keyFile == "" {
+		if insecure, _ := strconv.ParseBool(os.Getenv("LOG_TLS_INSECURE")); insecure {
+			return nil, nil
+		}
+		return nil, errPlaintext
+	}
+
+	c := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   os.Getenv("LOG_TLS_CLIENT_CA"),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// serverConfig returns a listener config that always hands out the current
+// certificates, negotiating nextProtos over ALPN.
+func (c *certReloader) serverConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config := c.current().Clone()
+			config.NextProtos = nextProtos
+			return config, nil
+		},
+	}
+}
+
+func (c *certReloader) current() *tls.Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.lastCheck) >= certCheckInterval {
+		c.lastCheck = time.Now()
+		if c.changed() {
+			if err := c.reload(); err != nil {
+				// Keep serving the certificate we have.
+				log.Println("Error reloading TLS certificates", err)
+			} else {
+				log.Println("Reloaded TLS certificates from", c.certFile)
+			}
+		}
+	}
+
+	return c.config
+}
+
+func (c *certReloader) load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastCheck = time.Now()
+	return c.reload()
+}
+
+// changed reports whether any of the files is newer than what was loaded.
+// Callers hold c.mu.
+func (c *certReloader) changed() bool {
+	for _, name := range []string{c.certFile, c.keyFile, c.caFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err == nil && info.ModTime().After(c.modified) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload reads the files and swaps in a new config. Callers hold c.mu.
+func (c *certReloader) reload() error {
+	modified := time.Now()
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return fmt.Errorf("loading TLS client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in %s", c.caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	c.config = config
+	c.modified = modified
+	return nil
+}
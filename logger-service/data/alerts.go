@@ -0,0 +1,306 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrInvalidAlertRule = errors.New("alert rule needs a threshold of at least zero and a window of at least one minute")
+
+// AlertRule fires when more than Threshold entries matching Name and Level
+// arrive within WindowMinutes. Empty Name or Level match anything.
+type AlertRule struct {
+	ID            string    `bson:"_id" json:"id"`
+	Description   string    `bson:"description" json:"description"`
+	Name          string    `bson:"name" json:"name"`
+	Level         string    `bson:"level" json:"level"`
+	Threshold     int       `bson:"threshold" json:"threshold"`
+	WindowMinutes int       `bson:"window_minutes" json:"window_minutes"`
+	Email         string    `bson:"email" json:"email"`
+	Enabled       bool      `bson:"enabled" json:"enabled"`
+	SilencedUntil time.Time `bson:"silenced_until" json:"silenced_until"`
+	LastFiredAt   time.Time `bson:"last_fired_at" json:"last_fired_at"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+func (r AlertRule) window() time.Duration {
+	return time.Duration(r.WindowMinutes) * time.Minute
+}
+
+func (r AlertRule) matches(entry LogEntry) bool {
+	if r.Name != "" && r.Name != entry.Name {
+		return false
+	}
+	if r.Level != "" && NormalizeLevel(r.Level) != NormalizeLevel(entry.Level) {
+		return false
+	}
+	return true
+}
+
+// Alert is what a Notifier receives when a rule fires.
+type Alert struct {
+	Tenant  string
+	Rule    AlertRule
+	FiredAt time.Time
+	Sample  LogEntry
+}
+
+type Notifier func(alert Alert) error
+
+// alertState tracks a rule between entries. seen holds at most Threshold+1
+// timestamps, enough to tell whether more than Threshold arrived in the
+// window. firing stays set until the rate drops back under the threshold, or
+// a whole window passes without a match, so an alert storm produces a single
+// notification.
+type alertState struct {
+	seen   []time.Time
+	firing bool
+}
+
+// Alerter wraps a LogStore and evaluates the alert rules of each tenant
+// against every entry of that tenant written through it.
+type Alerter struct {
+	LogStore
+	notify Notifier
+
+	mu sync.Mutex
+	// rules holds the rules of the tenants loaded so far.
+	rules map[string][]AlertRule
+	state map[scopedName]*alertState
+}
+
+func NewAlerter(store LogStore, notify Notifier) *Alerter {
+	return &Alerter{
+		LogStore: store,
+		notify:   notify,
+		rules:    make(map[string][]AlertRule),
+		state:    make(map[scopedName]*alertState),
+	}
+}
+
+// Reload reads the rules of the ctx tenant from the store, keeping the
+// window state of rules that still exist.
+func (a *Alerter) Reload(ctx context.Context) error {
+	tenant := TenantFrom(ctx)
+
+	rules, err := a.LogStore.AlertRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	loaded := make([]AlertRule, 0, len(rules))
+	kept := make(map[scopedName]bool, len(rules))
+	for _, rule := range rules {
+		loaded = append(loaded, *rule)
+
+		key := scopedName{tenant, rule.ID}
+		kept[key] = true
+		if _, ok := a.state[key]; !ok {
+			a.state[key] = &alertState{}
+		}
+	}
+	for key := range a.state {
+		if key.tenant == tenant && !kept[key] {
+			delete(a.state, key)
+		}
+	}
+	a.rules[tenant] = loaded
+
+	return nil
+}
+
+func (a *Alerter) Insert(ctx context.Context, entry LogEntry) error {
+	if err := a.LogStore.Insert(ctx, entry); err != nil {
+		return err
+	}
+	a.observe(ctx, entry)
+	return nil
+}
+
+func (a *Alerter) InsertMany(ctx context.Context, entries []LogEntry) ([]error, error) {
+	itemErrors, err := a.LogStore.InsertMany(ctx, entries)
+	if err != nil {
+		return itemErrors, err
+	}
+	for i, entry := range entries {
+		if itemErrors[i] == nil {
+			a.observe(ctx, entry)
+		}
+	}
+	return itemErrors, nil
+}
+
+// observe counts entry against the rules of the ctx tenant, loading them on
+// the tenant's first entry.
+func (a *Alerter) observe(ctx context.Context, entry LogEntry) {
+	tenant := TenantFrom(ctx)
+
+	a.mu.Lock()
+	_, loaded := a.rules[tenant]
+	a.mu.Unlock()
+	if !loaded {
+		if err := a.Reload(ctx); err != nil {
+			log.Println("Error loading alert rules of tenant", tenant, err)
+			return
+		}
+	}
+
+	now := time.Now()
+
+	var fired []Alert
+
+	a.mu.Lock()
+	for _, rule := range a.rules[tenant] {
+		if !rule.Enabled || !rule.matches(entry) {
+			continue
+		}
+
+		s := a.state[scopedName{tenant, rule.ID}]
+		cutoff := now.Add(-rule.window())
+
+		kept := s.seen[:0]
+		for _, t := range s.seen {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		// With a threshold of zero every match is over it, so a quiet
+		// window is what ends the storm.
+		if len(kept) == 0 {
+			s.firing = false
+		}
+		// A deduplicated entry counts as every entry it stands for.
+		for n := int64(0); n < max(entry.Repeat, 1) && n <= int64(rule.Threshold); n++ {
+			kept = append(kept, now)
+		}
+		if len(kept) > rule.Threshold+1 {
+			kept = kept[len(kept)-rule.Threshold-1:]
+		}
+		s.seen = kept
+
+		over := len(s.seen) > rule.Threshold
+		if !over {
+			s.firing = false
+			continue
+		}
+		if s.firing {
+			continue
+		}
+		s.firing = true
+
+		if now.Before(rule.SilencedUntil) {
+			continue
+		}
+
+		fired = append(fired, Alert{
+			Tenant:  tenant,
+			Rule:    rule,
+			FiredAt: now,
+			Sample:  entry,
+		})
+	}
+	a.mu.Unlock()
+
+	for _, alert := range fired {
+		go a.fire(alert)
+	}
+}
+
+func (a *Alerter) fire(alert Alert) {
+	log.Printf("Alert rule %s of tenant %s fired: more than %d %s entries in %d minutes", alert.Rule.ID, alert.Tenant, alert.Rule.Threshold, alert.Rule.Name, alert.Rule.WindowMinutes)
+
+	if err := a.notify(alert); err != nil {
+		log.Println("Error sending alert", alert.Rule.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(WithTenant(context.Background(), alert.Tenant), 15*time.Second)
+	defer cancel()
+
+	// Only LastFiredAt changes, so an edit of the rule made meanwhile stays.
+	err := a.LogStore.MarkAlertRuleFired(ctx, alert.Rule.ID, alert.FiredAt)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Println("Error recording alert", alert.Rule.ID, err)
+	}
+}
+
+func (a *Alerter) rule(ctx context.Context, id string) (*AlertRule, error) {
+	rules, err := a.LogStore.AlertRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.ID == id {
+			return rule, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (a *Alerter) Rules(ctx context.Context) ([]*AlertRule, error) {
+	return a.LogStore.AlertRules(ctx)
+}
+
+func (a *Alerter) Rule(ctx context.Context, id string) (*AlertRule, error) {
+	return a.rule(ctx, id)
+}
+
+// SaveRule creates the rule when rule.ID is empty and otherwise replaces it,
+// keeping its silence and last-fired time.
+func (a *Alerter) SaveRule(ctx context.Context, rule AlertRule) (*AlertRule, error) {
+	if rule.Threshold < 0 || rule.WindowMinutes < 1 {
+		return nil, ErrInvalidAlertRule
+	}
+	if rule.Level != "" {
+		rule.Level = NormalizeLevel(rule.Level)
+	}
+
+	if rule.ID == "" {
+		rule.ID = primitive.NewObjectID().Hex()
+	} else {
+		existing, err := a.rule(ctx, rule.ID)
+		if err != nil {
+			return nil, err
+		}
+		rule.SilencedUntil = existing.SilencedUntil
+		rule.LastFiredAt = existing.LastFiredAt
+	}
+	rule.UpdatedAt = time.Now()
+
+	if err := a.LogStore.SaveAlertRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return &rule, a.Reload(ctx)
+}
+
+func (a *Alerter) DeleteRule(ctx context.Context, id string) error {
+	if err := a.LogStore.DeleteAlertRule(ctx, id); err != nil {
+		return err
+	}
+	return a.Reload(ctx)
+}
+
+// Silence suppresses notifications from the rule until the given time. A
+// zero time lifts the silence.
+func (a *Alerter) Silence(ctx context.Context, id string, until time.Time) (*AlertRule, error) {
+	rule, err := a.rule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rule.SilencedUntil = until
+	rule.UpdatedAt = time.Now()
+
+	if err := a.LogStore.SaveAlertRule(ctx, *rule); err != nil {
+		return nil, err
+	}
+
+	return rule, a.Reload(ctx)
+}
@@ -0,0 +1,520 @@
+package data
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	archiveManifest = "manifest.json"
+	// archiveDateLayout names the day an archive part covers.
+	archiveDateLayout = "2006-01-02"
+	// archiveRunLayout names a run in its file names, down to the
+	// nanosecond so runs in quick succession never collide.
+	archiveRunLayout = "20060102T150405.000000000"
+	// archiveMaxOpen bounds the day files one run keeps open per tenant;
+	// past it the least recent file is closed and the day continues in a
+	// new part.
+	archiveMaxOpen   = 32
+	archiveBatchSize = 1000
+)
+
+var ErrInvalidArchiveRange = errors.New("archive range needs from and to dates with from not after to")
+
+// ArchivePart is one gzipped NDJSON file holding entries of a single tenant
+// created on a single UTC day. A day can span several parts, one per run.
+type ArchivePart struct {
+	Tenant     string    `json:"tenant"`
+	Date       string    `json:"date"`
+	File       string    `json:"file"`
+	Entries    int64     `json:"entries"`
+	Bytes      int64     `json:"bytes"`
+	SHA256     string    `json:"sha256"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	ArchivedAt time.Time `json:"archived_at"`
+	RestoredAt time.Time `json:"restored_at"`
+}
+
+// ArchiveManifest lists every part in the archive directory. It is
+// rewritten atomically after each run and restore.
+type ArchiveManifest struct {
+	Parts []ArchivePart `json:"parts"`
+}
+
+// ArchiveRun reports what Archive moved out of the store.
+type ArchiveRun struct {
+	Cutoff   time.Time     `json:"cutoff"`
+	Archived int64         `json:"archived"`
+	Removed  int64         `json:"removed"`
+	Parts    []ArchivePart `json:"parts"`
+}
+
+// Archiver moves old entries out of the store into compressed,
+// date-partitioned NDJSON files under dir:
+//
+//	<dir>/<tenant>/<yyyy>/<mm>/<yyyy-mm-dd>-<run>-<n>.ndjson.gz
+//
+// and reimports them on request.
+type Archiver struct {
+	store LogStore
+	dir   string
+	mu    sync.Mutex
+}
+
+func NewArchiver(store LogStore, dir string) (*Archiver, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Archiver{store: store, dir: dir}, nil
+}
+
+// Manifest returns the parts of the ctx tenant archived so far.
+func (a *Archiver) Manifest(ctx context.Context) (*ArchiveManifest, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	manifest, err := a.readManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFrom(ctx)
+	parts := manifest.Parts[:0]
+	for _, part := range manifest.Parts {
+		if part.Tenant == tenant {
+			parts = append(parts, part)
+		}
+	}
+	manifest.Parts = parts
+
+	return manifest, nil
+}
+
+func (a *Archiver) readManifest() (*ArchiveManifest, error) {
+	manifest := &ArchiveManifest{Parts: []ArchivePart{}}
+
+	raw, err := os.ReadFile(filepath.Join(a.dir, archiveManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, manifest); err != nil {
+		return nil, fmt.Errorf("reading archive manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+func (a *Archiver) writeManifest(manifest *ArchiveManifest) error {
+	raw, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(a.dir, archiveManifest+".tmp")
+	if err := writeFileSync(tmp, raw); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(a.dir, archiveManifest))
+}
+
+func writeFileSync(path string, raw []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(raw); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ArchiveAll moves every entry created before cutoff, in every tenant, into
+// the archive.
+func (a *Archiver) ArchiveAll(ctx context.Context, cutoff time.Time) (*ArchiveRun, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tenants, err := a.store.Tenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &ArchiveRun{Cutoff: cutoff, Parts: []ArchivePart{}}
+	runID := newArchiveRunID()
+
+	for _, tenant := range tenants {
+		if err := a.archive(WithTenant(ctx, tenant), runID, run); err != nil {
+			return run, err
+		}
+	}
+
+	return run, nil
+}
+
+// Archive moves the entries of the ctx tenant created before cutoff into the
+// archive.
+func (a *Archiver) Archive(ctx context.Context, cutoff time.Time) (*ArchiveRun, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	run := &ArchiveRun{Cutoff: cutoff, Parts: []ArchivePart{}}
+	runID := newArchiveRunID()
+
+	return run, a.archive(ctx, runID, run)
+}
+
+func newArchiveRunID() string {
+	return time.Now().UTC().Format(archiveRunLayout)
+}
+
+// archive moves the entries of the ctx tenant created before run.Cutoff into
+// the archive, adding what it did to run. Restored entries are already in
+// the archive and stay in the store. Entries are only removed from the
+// store once their files and the manifest are on disk. Callers hold a.mu.
+func (a *Archiver) archive(ctx context.Context, runID string, run *ArchiveRun) error {
+	tenant := TenantFrom(ctx)
+
+	writer := &archiveWriter{dir: a.dir, tenant: tenant, run: runID, open: make(map[string]*archiveFile)}
+	var ids []string
+
+	_, err := a.store.Export(ctx, LogQuery{To: run.Cutoff, SkipRestored: true}, "", 0, func(entry *LogEntry) error {
+		ids = append(ids, entry.ID)
+		return writer.write(entry)
+	})
+	if err == nil {
+		err = writer.closeAll()
+	}
+	if err != nil {
+		writer.abort()
+		log.Println("Error archiving logs of tenant", tenant, err)
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	manifest, err := a.readManifest()
+	if err == nil {
+		manifest.Parts = append(manifest.Parts, writer.parts...)
+		err = a.writeManifest(manifest)
+	}
+	if err != nil {
+		writer.abort()
+		log.Println("Error writing archive manifest", err)
+		return err
+	}
+
+	run.Parts = append(run.Parts, writer.parts...)
+	run.Archived += int64(len(ids))
+
+	for start := 0; start < len(ids); start += archiveBatchSize {
+		end := min(start+archiveBatchSize, len(ids))
+		removed, err := a.store.DeleteIDs(ctx, ids[start:end])
+		run.Removed += removed
+		if err != nil {
+			// The entries are archived; the next run archives the
+			// leftovers again into new parts.
+			log.Println("Error removing archived logs of tenant", tenant, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore reimports the parts of tenant covering the days from to to,
+// inclusive, that have not been restored yet, and returns the number of
+// entries inserted. Entries keep their ids and get RestoredAt, which keeps
+// later runs from archiving them again and retention from purging them
+// before they have been back for the policy's days.
+func (a *Archiver) Restore(ctx context.Context, tenant string, from, to time.Time) (int64, error) {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0, ErrInvalidArchiveRange
+	}
+	first, last := from.UTC().Format(archiveDateLayout), to.UTC().Format(archiveDateLayout)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	manifest, err := a.readManifest()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = WithTenant(ctx, tenant)
+
+	var restored int64
+	for i := range manifest.Parts {
+		part := &manifest.Parts[i]
+		if part.Tenant != tenant || part.Date < first || part.Date > last || !part.RestoredAt.IsZero() {
+			continue
+		}
+
+		n, err := a.restorePart(ctx, part)
+		restored += n
+		if err != nil {
+			log.Println("Error restoring archive part", part.File, err)
+			return restored, err
+		}
+
+		part.RestoredAt = time.Now()
+		if err := a.writeManifest(manifest); err != nil {
+			return restored, err
+		}
+	}
+
+	return restored, nil
+}
+
+func (a *Archiver) restorePart(ctx context.Context, part *ArchivePart) (int64, error) {
+	path := filepath.Join(a.dir, part.File)
+	if err := checkArchiveFile(path, part.SHA256); err != nil {
+		return 0, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer gz.Close()
+
+	var restored int64
+	batch := make([]LogEntry, 0, archiveBatchSize)
+	now := time.Now()
+
+	insert := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		itemErrors, err := a.store.InsertMany(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, itemErr := range itemErrors {
+			switch {
+			case itemErr == nil:
+				restored++
+			case errors.Is(itemErr, ErrConflict):
+				// Already back from an earlier, interrupted restore.
+			default:
+				return itemErr
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	dec := json.NewDecoder(gz)
+	for {
+		var entry LogEntry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return restored, err
+		}
+
+		// Restored entries keep their id, which makes restoring a part
+		// again harmless, but do not rejoin an audit chain.
+		entry.Seq, entry.PrevHash, entry.Hash = 0, "", ""
+		entry.RestoredAt = now
+		batch = append(batch, entry)
+
+		if len(batch) == archiveBatchSize {
+			if err := insert(); err != nil {
+				return restored, err
+			}
+		}
+	}
+
+	return restored, insert()
+}
+
+func checkArchiveFile(path, sum string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != sum {
+		return fmt.Errorf("archive file %s does not match its checksum", path)
+	}
+	return nil
+}
+
+// archiveWriter spreads the entries of one tenant and run over day files.
+type archiveWriter struct {
+	dir    string
+	tenant string
+	run    string
+	open   map[string]*archiveFile
+	order  []string
+	count  map[string]int
+	parts  []ArchivePart
+	files  []string
+}
+
+type archiveFile struct {
+	part ArchivePart
+	file *os.File
+	sum  hash.Hash
+	gz   *gzip.Writer
+	enc  *json.Encoder
+	buf  *bufio.Writer
+}
+
+func (w *archiveWriter) write(entry *LogEntry) error {
+	day := entry.CreatedAt.UTC().Format(archiveDateLayout)
+
+	f, ok := w.open[day]
+	if !ok {
+		if len(w.open) >= archiveMaxOpen {
+			if err := w.close(w.order[0]); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if f, err = w.create(day); err != nil {
+			return err
+		}
+		w.open[day] = f
+		w.order = append(w.order, day)
+	}
+
+	if err := f.enc.Encode(entry); err != nil {
+		return err
+	}
+
+	f.part.Entries++
+	if f.part.From.IsZero() || entry.CreatedAt.Before(f.part.From) {
+		f.part.From = entry.CreatedAt
+	}
+	if entry.CreatedAt.After(f.part.To) {
+		f.part.To = entry.CreatedAt
+	}
+
+	return nil
+}
+
+func (w *archiveWriter) create(day string) (*archiveFile, error) {
+	if w.count == nil {
+		w.count = make(map[string]int)
+	}
+	w.count[day]++
+
+	rel := filepath.Join(w.tenant, day[:4], day[5:7], fmt.Sprintf("%s-%s-%d.ndjson.gz", day, w.run, w.count[day]))
+	path := filepath.Join(w.dir, rel)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w.files = append(w.files, path)
+
+	f := &archiveFile{
+		part: ArchivePart{Tenant: w.tenant, Date: day, File: rel},
+		file: file,
+		sum:  sha256.New(),
+	}
+	f.buf = bufio.NewWriter(io.MultiWriter(file, f.sum))
+	f.gz = gzip.NewWriter(f.buf)
+	f.enc = json.NewEncoder(f.gz)
+
+	return f, nil
+}
+
+// close finishes the file of day and records it as a part.
+func (w *archiveWriter) close(day string) error {
+	f := w.open[day]
+	delete(w.open, day)
+	for i, d := range w.order {
+		if d == day {
+			w.order = append(w.order[:i], w.order[i+1:]...)
+			break
+		}
+	}
+
+	err := f.gz.Close()
+	if err == nil {
+		err = f.buf.Flush()
+	}
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(filepath.Join(w.dir, f.part.File))
+	if err != nil {
+		return err
+	}
+	f.part.Bytes = info.Size()
+	f.part.SHA256 = hex.EncodeToString(f.sum.Sum(nil))
+	f.part.ArchivedAt = time.Now()
+	w.parts = append(w.parts, f.part)
+
+	return nil
+}
+
+func (w *archiveWriter) closeAll() error {
+	for len(w.order) > 0 {
+		if err := w.close(w.order[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abort removes every file of the run, which the manifest never listed.
+func (w *archiveWriter) abort() {
+	for _, f := range w.open {
+		f.file.Close()
+	}
+	for _, path := range w.files {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Error removing unfinished archive file", path, err)
+		}
+	}
+}
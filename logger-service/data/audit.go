@@ -0,0 +1,249 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrAuditImmutable = errors.New("log entries cannot be changed or removed in audit mode")
+
+// errChainBroken stops the walk in Verify at the first broken link.
+var errChainBroken = errors.New("audit chain broken")
+
+// maxChainRetries bounds the retries of a write that lost the race for a
+// Seq.
+const maxChainRetries = 5
+
+// Auditor wraps a LogStore and links every entry written through it into a
+// hash chain: each entry gets the next Seq, the Hash of the previous entry
+// as PrevHash, and a Hash over its own content and PrevHash. Editing,
+// removing or reordering stored entries breaks the chain, and Update, Drop
+// and DeleteBefore are refused. Each tenant has its own chain.
+//
+// Entries are written one at a time under a lock so a failed write never
+// leaves a gap in the chain. The head is cached, but the store refuses a Seq
+// that is taken, so when another replica or an earlier process has extended
+// the chain the head is read again from the store and the write retried.
+type Auditor struct {
+	LogStore
+
+	mu    sync.Mutex
+	heads map[string]*auditHead
+}
+
+// auditHead is the last link of a tenant's chain.
+type auditHead struct {
+	seq  int64
+	last string
+}
+
+func NewAuditor(store LogStore) *Auditor {
+	return &Auditor{
+		LogStore: store,
+		heads:    make(map[string]*auditHead),
+	}
+}
+
+// head returns the chain head of the tenant ctx is scoped to, continuing
+// from the last chained entry in the store the first time. Callers hold a.mu.
+func (a *Auditor) head(ctx context.Context) (*auditHead, error) {
+	tenant := TenantFrom(ctx)
+	if head, ok := a.heads[tenant]; ok {
+		return head, nil
+	}
+
+	head := &auditHead{}
+	last, err := a.LogStore.LastChained(ctx)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		head.seq = last.Seq
+		head.last = last.Hash
+	}
+
+	a.heads[tenant] = head
+	return head, nil
+}
+
+// auditContent is what the hash covers. CreatedAt is kept to millisecond
+// precision, which is what Mongo stores.
+type auditContent struct {
+	Seq       int64  `json:"seq"`
+	PrevHash  string `json:"prev_hash"`
+	Name      string `json:"name"`
+	Data      string `json:"data"`
+	Level     string `json:"level"`
+	Host      string `json:"host"`
+	Facility  string `json:"facility"`
+	CreatedAt string `json:"created_at"`
+	Repeat    int64  `json:"repeat,omitempty"`
+}
+
+func auditHash(entry *LogEntry) string {
+	content, _ := json.Marshal(auditContent{
+		Seq:       entry.Seq,
+		PrevHash:  entry.PrevHash,
+		Name:      entry.Name,
+		Data:      entry.Data,
+		Level:     entry.Level,
+		Host:      entry.Host,
+		Facility:  entry.Facility,
+		CreatedAt: entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Repeat:    entry.Repeat,
+	})
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *Auditor) Insert(ctx context.Context, entry LogEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.insert(ctx, entry)
+}
+
+func (a *Auditor) InsertMany(ctx context.Context, entries []LogEntry) ([]error, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	itemErrors := make([]error, len(entries))
+	if _, err := a.head(ctx); err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		itemErrors[i] = a.insert(ctx, entry)
+	}
+	return itemErrors, nil
+}
+
+// insert chains entry onto the last one and stores it. Callers hold a.mu.
+func (a *Auditor) insert(ctx context.Context, entry LogEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Millisecond)
+	entry.Level = NormalizeLevel(entry.Level)
+
+	for attempt := 0; ; attempt++ {
+		head, err := a.head(ctx)
+		if err != nil {
+			return err
+		}
+
+		entry.Seq = head.seq + 1
+		entry.PrevHash = head.last
+		entry.Hash = auditHash(&entry)
+
+		err = a.LogStore.Insert(ctx, entry)
+		if errors.Is(err, ErrConflict) && attempt < maxChainRetries {
+			// Someone else took the Seq; continue from their head.
+			delete(a.heads, TenantFrom(ctx))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		head.seq = entry.Seq
+		head.last = entry.Hash
+		return nil
+	}
+}
+
+func (a *Auditor) Update(ctx context.Context, entry LogEntry) (*UpdateResult, error) {
+	return nil, ErrAuditImmutable
+}
+
+func (a *Auditor) Drop(ctx context.Context) error {
+	return ErrAuditImmutable
+}
+
+func (a *Auditor) DeleteBefore(ctx context.Context, cutoff time.Time, name string, exclude []string) (int64, error) {
+	return 0, ErrAuditImmutable
+}
+
+func (a *Auditor) DeleteIDs(ctx context.Context, ids []string) (int64, error) {
+	return 0, ErrAuditImmutable
+}
+
+type AuditReport struct {
+	// Intact is true when every link from the first entry up to the last
+	// written one checks out.
+	Intact   bool        `json:"intact"`
+	Verified int64       `json:"verified"`
+	Head     string      `json:"head,omitempty"`
+	Broken   *BrokenLink `json:"broken,omitempty"`
+}
+
+// BrokenLink is the first entry whose link does not check out.
+type BrokenLink struct {
+	Seq    int64  `json:"seq"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Verify walks the chain of the tenant ctx is scoped to from the first
+// entry and reports the first broken link.
+func (a *Auditor) Verify(ctx context.Context) (*AuditReport, error) {
+	a.mu.Lock()
+	current, err := a.head(ctx)
+	if err != nil {
+		a.mu.Unlock()
+		return nil, err
+	}
+	head, headSeq := current.last, current.seq
+	a.mu.Unlock()
+
+	report := &AuditReport{}
+	expected := int64(1)
+	prev := ""
+
+	err = a.LogStore.Chain(ctx, 1, func(entry *LogEntry) error {
+		if entry.Seq > headSeq {
+			// Written after the walk started.
+			return errChainBroken
+		}
+
+		switch {
+		case entry.Seq < expected:
+			report.Broken = &BrokenLink{Seq: entry.Seq, ID: entry.ID, Reason: fmt.Sprintf("entry %d appears more than once", entry.Seq)}
+		case entry.Seq > expected:
+			report.Broken = &BrokenLink{Seq: expected, Reason: fmt.Sprintf("entry %d is missing", expected)}
+		case entry.PrevHash != prev:
+			report.Broken = &BrokenLink{Seq: entry.Seq, ID: entry.ID, Reason: "prev_hash does not match the previous entry"}
+		case auditHash(entry) != entry.Hash:
+			report.Broken = &BrokenLink{Seq: entry.Seq, ID: entry.ID, Reason: "content does not match its hash"}
+		}
+		if report.Broken != nil {
+			return errChainBroken
+		}
+
+		report.Verified++
+		prev = entry.Hash
+		expected++
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return nil, err
+	}
+
+	if report.Broken == nil && report.Verified < headSeq {
+		report.Broken = &BrokenLink{Seq: expected, Reason: fmt.Sprintf("entry %d is missing", expected)}
+	}
+	if report.Broken == nil && prev != head {
+		report.Broken = &BrokenLink{Seq: headSeq, Reason: "last entry does not match the chain head"}
+	}
+
+	report.Intact = report.Broken == nil
+	report.Head = head
+
+	return report, nil
+}
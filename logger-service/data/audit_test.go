@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chainedStore returns a MemoryStore holding n entries chained in the tenant
+// of ctx, the Auditor that chained them and the stored entries in Seq order.
+func chainedStore(t *testing.T, ctx context.Context, n int) (*MemoryStore, *Auditor, []*LogEntry) {
+	t.Helper()
+
+	store := NewMemoryStore()
+	auditor := NewAuditor(store)
+	for i := 0; i < n; i++ {
+		if err := auditor.Insert(ctx, LogEntry{Name: "svc", Data: fmt.Sprintf("entry %d", i+1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var entries []*LogEntry
+	err := store.Chain(ctx, 1, func(entry *LogEntry) error {
+		entries = append(entries, store.byID[entry.ID])
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, auditor, entries
+}
+
+func TestAuditVerify(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		tamper func(store *MemoryStore, entries []*LogEntry)
+		seq    int64
+		reason string
+	}{
+		{
+			name:   "intact",
+			tamper: func(*MemoryStore, []*LogEntry) {},
+		},
+		{
+			name:   "edited data",
+			tamper: func(_ *MemoryStore, entries []*LogEntry) { entries[2].Data = "forged" },
+			seq:    3,
+			reason: "content does not match its hash",
+		},
+		{
+			name: "edited timestamp",
+			tamper: func(_ *MemoryStore, entries []*LogEntry) {
+				entries[1].CreatedAt = entries[1].CreatedAt.Add(time.Second)
+			},
+			seq:    2,
+			reason: "content does not match its hash",
+		},
+		{
+			name: "rehashed entry",
+			tamper: func(_ *MemoryStore, entries []*LogEntry) {
+				entries[1].Data = "forged"
+				entries[1].Hash = auditHash(entries[1])
+			},
+			seq:    3,
+			reason: "prev_hash does not match the previous entry",
+		},
+		{
+			name:   "removed entry",
+			tamper: func(store *MemoryStore, entries []*LogEntry) { store.remove(map[string]bool{entries[1].ID: true}) },
+			seq:    2,
+			reason: "entry 2 is missing",
+		},
+		{
+			name:   "removed last entry",
+			tamper: func(store *MemoryStore, entries []*LogEntry) { store.remove(map[string]bool{entries[4].ID: true}) },
+			seq:    5,
+			reason: "entry 5 is missing",
+		},
+		{
+			name:   "duplicated entry",
+			tamper: func(_ *MemoryStore, entries []*LogEntry) { entries[2].Seq = 2 },
+			seq:    2,
+			reason: "entry 2 appears more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, auditor, entries := chainedStore(t, ctx, 5)
+			tt.tamper(store, entries)
+
+			// The writing Auditor knows the head, so it also notices a
+			// removed last entry.
+			report, err := auditor.Verify(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.reason == "" {
+				if !report.Intact || report.Verified != 5 || report.Head != entries[4].Hash {
+					t.Errorf("report = %+v, want an intact chain of 5", report)
+				}
+				return
+			}
+			if report.Intact || report.Broken == nil {
+				t.Fatalf("report = %+v, want a broken chain", report)
+			}
+			if report.Broken.Seq != tt.seq || report.Broken.Reason != tt.reason {
+				t.Errorf("broken at %d: %q, want %d: %q", report.Broken.Seq, report.Broken.Reason, tt.seq, tt.reason)
+			}
+		})
+	}
+}
+
+func TestAuditTenantsHaveTheirOwnChain(t *testing.T) {
+	store := NewMemoryStore()
+	auditor := NewAuditor(store)
+
+	acme := WithTenant(context.Background(), "acme")
+	other := WithTenant(context.Background(), "other")
+	for _, ctx := range []context.Context{acme, other, acme} {
+		if err := auditor.Insert(ctx, LogEntry{Name: "svc", Data: TenantFrom(ctx)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for ctx, want := range map[context.Context]int64{acme: 2, other: 1} {
+		report, err := auditor.Verify(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Intact || report.Verified != want {
+			t.Errorf("%s: report = %+v, want %d intact entries", TenantFrom(ctx), report, want)
+		}
+	}
+}
+
+func TestAuditReplicasShareTheChain(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	replicas := []*Auditor{NewAuditor(store), NewAuditor(store)}
+
+	var wg sync.WaitGroup
+	for _, auditor := range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := auditor.Insert(ctx, LogEntry{Name: "svc", Data: "x"}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	report, err := NewAuditor(store).Verify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Intact || report.Verified != 40 {
+		t.Errorf("report = %+v, want an intact chain of 40", report)
+	}
+}
+
+func TestAuditRefusesChanges(t *testing.T) {
+	ctx := context.Background()
+	_, auditor, entries := chainedStore(t, ctx, 1)
+
+	if _, err := auditor.Update(ctx, LogEntry{ID: entries[0].ID, Name: "x"}); !errors.Is(err, ErrAuditImmutable) {
+		t.Errorf("Update returned %v, want ErrAuditImmutable", err)
+	}
+	if err := auditor.Drop(ctx); !errors.Is(err, ErrAuditImmutable) {
+		t.Errorf("Drop returned %v, want ErrAuditImmutable", err)
+	}
+	if _, err := auditor.DeleteBefore(ctx, time.Now(), "", nil); !errors.Is(err, ErrAuditImmutable) {
+		t.Errorf("DeleteBefore returned %v, want ErrAuditImmutable", err)
+	}
+	if _, err := auditor.DeleteIDs(ctx, []string{entries[0].ID}); !errors.Is(err, ErrAuditImmutable) {
+		t.Errorf("DeleteIDs returned %v, want ErrAuditImmutable", err)
+	}
+}
@@ -0,0 +1,216 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrBufferFull = errors.New("log write buffer is full")
+var ErrBufferClosed = errors.New("log write buffer is closed")
+
+var (
+	bufferDepth         = new(expvar.Int)
+	bufferFlushes       = expvar.NewInt("log_buffer_flushes")
+	bufferFlushedItems  = expvar.NewInt("log_buffer_flushed_entries")
+	bufferFlushErrors   = expvar.NewInt("log_buffer_flush_errors")
+	bufferFlushLatency  = expvar.NewFloat("log_buffer_last_flush_ms")
+	bufferRejectedItems = expvar.NewInt("log_buffer_rejected_entries")
+)
+
+func init() {
+	expvar.Publish("log_buffer_depth", bufferDepth)
+}
+
+type BufferConfig struct {
+	// BatchSize is the number of entries that triggers a flush.
+	BatchSize int
+	// FlushInterval is the longest an entry waits before being flushed.
+	FlushInterval time.Duration
+	// Capacity is the number of entries the buffer holds before Insert blocks.
+	Capacity int
+	// EnqueueTimeout is how long Insert blocks on a full buffer before giving up.
+	EnqueueTimeout time.Duration
+}
+
+// BufferedStore wraps a LogStore and coalesces single Inserts into
+// InsertMany calls, flushing when a batch is full or the flush interval
+// elapses. Every other call goes straight to the wrapped store.
+type BufferedStore struct {
+	LogStore
+	config  BufferConfig
+	entries chan LogEntry
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewBufferedStore starts buffering writes to store. Call Close during
+// shutdown to flush what is left.
+func NewBufferedStore(store LogStore, config BufferConfig) *BufferedStore {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.Capacity < config.BatchSize {
+		config.Capacity = config.BatchSize * 10
+	}
+	if config.EnqueueTimeout <= 0 {
+		config.EnqueueTimeout = 5 * time.Second
+	}
+
+	b := &BufferedStore{
+		LogStore: store,
+		config:   config,
+		entries:  make(chan LogEntry, config.Capacity),
+		done:     make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go b.run()
+
+	return b
+}
+
+// Insert queues an entry. When the buffer is full it blocks for up to
+// EnqueueTimeout, pushing back on the caller instead of dropping the entry.
+func (b *BufferedStore) Insert(ctx context.Context, entry LogEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	entry.Tenant = TenantFrom(ctx)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return ErrBufferClosed
+	}
+
+	select {
+	case b.entries <- entry:
+		bufferDepth.Set(int64(len(b.entries)))
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(b.config.EnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case b.entries <- entry:
+		bufferDepth.Set(int64(len(b.entries)))
+		return nil
+	case <-timer.C:
+		bufferRejectedItems.Add(1)
+		log.Println("Log write buffer full, rejecting entry", entry.Name)
+		return ErrBufferFull
+	case <-ctx.Done():
+		bufferRejectedItems.Add(1)
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting entries and flushes everything still queued.
+func (b *BufferedStore) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.done)
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+func (b *BufferedStore) run() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]LogEntry, 0, b.config.BatchSize)
+
+	for {
+		select {
+		case entry := <-b.entries:
+			batch = append(batch, entry)
+			if len(batch) >= b.config.BatchSize {
+				batch = b.flush(batch)
+			}
+		case <-ticker.C:
+			batch = b.flush(batch)
+		case <-b.done:
+			for {
+				select {
+				case entry := <-b.entries:
+					batch = append(batch, entry)
+					if len(batch) >= b.config.BatchSize {
+						batch = b.flush(batch)
+					}
+				default:
+					b.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *BufferedStore) flush(batch []LogEntry) []LogEntry {
+	bufferDepth.Set(int64(len(b.entries)))
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	for tenant, entries := range byTenant(batch) {
+		b.insert(WithTenant(ctx, tenant), entries)
+	}
+	bufferFlushLatency.Set(float64(time.Since(start).Microseconds()) / 1000)
+	bufferFlushes.Add(1)
+
+	return batch[:0]
+}
+
+// byTenant splits batch by tenant, keeping the order within each tenant.
+func byTenant(batch []LogEntry) map[string][]LogEntry {
+	groups := make(map[string][]LogEntry)
+	for _, entry := range batch {
+		groups[entry.Tenant] = append(groups[entry.Tenant], entry)
+	}
+	return groups
+}
+
+func (b *BufferedStore) insert(ctx context.Context, entries []LogEntry) {
+	itemErrors, err := b.LogStore.InsertMany(ctx, entries)
+	if err != nil {
+		bufferFlushErrors.Add(1)
+		log.Println("Error flushing log buffer", err)
+		return
+	}
+
+	failed := 0
+	for _, itemErr := range itemErrors {
+		if itemErr != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		bufferFlushErrors.Add(1)
+		log.Printf("Log buffer flush dropped %d of %d entries", failed, len(entries))
+	}
+	bufferFlushedItems.Add(int64(len(entries) - failed))
+}
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrFilterRequired keeps DeleteMany from removing every entry by accident.
+var ErrFilterRequired = errors.New("at least one of name, level, from or to is required")
+
+// deleteChunk is how many ids DeleteMany removes per DeleteIDs call.
+const deleteChunk = 1000
+
+// Entries deletes single entries and filtered sets of entries.
+type Entries struct {
+	store LogStore
+}
+
+// Delete removes the entry with id, or returns ErrNotFound.
+func (e *Entries) Delete(ctx context.Context, id string) error {
+	removed, err := e.store.DeleteIDs(ctx, []string{id})
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteMany removes the entries matching the filters of q and returns how
+// many were removed. With dryRun it only counts them. Pagination fields are
+// ignored.
+func (e *Entries) DeleteMany(ctx context.Context, q LogQuery, dryRun bool) (int64, error) {
+	if q.Name == "" && q.Level == "" && q.From.IsZero() && q.To.IsZero() {
+		return 0, ErrFilterRequired
+	}
+
+	if dryRun {
+		q.Page, q.PageSize = 1, 1
+		page, err := e.store.Find(ctx, q)
+		if err != nil {
+			return 0, err
+		}
+		return page.Total, nil
+	}
+
+	var removed int64
+	ids := make([]string, 0, deleteChunk)
+	flush := func() error {
+		n, err := e.store.DeleteIDs(ctx, ids)
+		removed += n
+		ids = ids[:0]
+		return err
+	}
+
+	_, err := e.store.Export(ctx, q, "", 0, func(entry *LogEntry) error {
+		ids = append(ids, entry.ID)
+		if len(ids) == deleteChunk {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(ids) > 0 {
+		err = flush()
+	}
+
+	return removed, err
+}
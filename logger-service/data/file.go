@@ -0,0 +1,318 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// FileStore is an append-only journal of NDJSON records on local disk. Every
+// change, including updates and deletions, is appended as a new record and
+// the journal is replayed into memory on start, so queries are served by the
+// embedded MemoryStore.
+type FileStore struct {
+	*MemoryStore
+	file *os.File
+	enc  *json.Encoder
+}
+
+type fileRecord struct {
+	Op     string           `json:"op"`
+	Entry  *LogEntry        `json:"entry,omitempty"`
+	IDs    []string         `json:"ids,omitempty"`
+	Policy *RetentionPolicy `json:"policy,omitempty"`
+	Rule   *AlertRule       `json:"rule,omitempty"`
+	Name   string           `json:"name,omitempty"`
+	Tenant string           `json:"tenant,omitempty"`
+}
+
+const (
+	fileOpInsert       = "insert"
+	fileOpUpdate       = "update"
+	fileOpDelete       = "delete"
+	fileOpDrop         = "drop"
+	fileOpPolicy       = "policy"
+	fileOpDeletePolicy = "delete_policy"
+	fileOpRule         = "rule"
+	fileOpDeleteRule   = "delete_rule"
+)
+
+// NewFileStore replays the journal at path, creating it if needed, and
+// opens it for appending.
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{MemoryStore: NewMemoryStore()}
+
+	if err := f.replay(path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("Error opening log file store", err)
+		return nil, err
+	}
+	f.file = file
+	f.enc = json.NewEncoder(file)
+
+	return f, nil
+}
+
+func (f *FileStore) replay(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Println("Error opening log file store", err)
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if len(raw) > 0 {
+			var record fileRecord
+			if jsonErr := json.Unmarshal(raw, &record); jsonErr != nil {
+				// A torn final write is expected after a crash; skip it.
+				log.Println("Skipping unreadable log file record on line", line, jsonErr)
+			} else {
+				f.apply(record)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Println("Error reading log file store", err)
+			return err
+		}
+	}
+}
+
+// apply replays a record into memory. Records written before tenants
+// existed belong to DefaultTenant. Callers hold the write lock or have
+// exclusive access.
+func (f *FileStore) apply(record fileRecord) {
+	if record.Tenant == "" {
+		record.Tenant = DefaultTenant
+	}
+
+	switch record.Op {
+	case fileOpInsert:
+		if record.Entry != nil {
+			entry := *record.Entry
+			entry.Tenant = record.Tenant
+			f.add(entry)
+		}
+	case fileOpUpdate:
+		if record.Entry != nil {
+			f.update(*record.Entry)
+		}
+	case fileOpDelete:
+		ids := make(map[string]bool, len(record.IDs))
+		for _, id := range record.IDs {
+			ids[id] = true
+		}
+		f.remove(ids)
+	case fileOpDrop:
+		f.drop(record.Tenant)
+	case fileOpPolicy:
+		if record.Policy != nil {
+			f.policies[scopedName{record.Tenant, record.Policy.Name}] = *record.Policy
+		}
+	case fileOpDeletePolicy:
+		delete(f.policies, scopedName{record.Tenant, record.Name})
+	case fileOpRule:
+		if record.Rule != nil {
+			f.rules[scopedName{record.Tenant, record.Rule.ID}] = *record.Rule
+		}
+	case fileOpDeleteRule:
+		delete(f.rules, scopedName{record.Tenant, record.Name})
+	}
+}
+
+// write appends record to the journal and then applies it in memory, so a
+// failed write leaves memory matching the file. Callers hold the write lock.
+func (f *FileStore) write(record fileRecord) error {
+	if err := f.enc.Encode(record); err != nil {
+		log.Println("Error writing log file store", err)
+		return err
+	}
+	f.apply(record)
+	return nil
+}
+
+func (f *FileStore) Insert(ctx context.Context, entry LogEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.idTaken(entry.ID) || f.seqTaken(TenantFrom(ctx), entry.Seq) {
+		return ErrConflict
+	}
+
+	stored := newEntry(entry, time.Now())
+	stored.ID = entry.ID
+	if stored.ID == "" {
+		stored.ID = primitive.NewObjectID().Hex()
+	}
+
+	return f.write(fileRecord{Op: fileOpInsert, Entry: &stored, Tenant: TenantFrom(ctx)})
+}
+
+func (f *FileStore) InsertMany(ctx context.Context, entries []LogEntry) ([]error, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	itemErrors := make([]error, len(entries))
+	now := time.Now()
+	for i, entry := range entries {
+		if f.idTaken(entry.ID) || f.seqTaken(TenantFrom(ctx), entry.Seq) {
+			itemErrors[i] = ErrConflict
+			continue
+		}
+		stored := newEntry(entry, now)
+		stored.ID = entry.ID
+		if stored.ID == "" {
+			stored.ID = primitive.NewObjectID().Hex()
+		}
+		itemErrors[i] = f.write(fileRecord{Op: fileOpInsert, Entry: &stored, Tenant: TenantFrom(ctx)})
+	}
+
+	return itemErrors, nil
+}
+
+func (f *FileStore) Update(ctx context.Context, entry LogEntry) (*UpdateResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result, err := f.prepareUpdate(TenantFrom(ctx), entry)
+	if err != nil {
+		return nil, err
+	}
+
+	update := LogEntry{
+		ID:        entry.ID,
+		Name:      entry.Name,
+		Data:      entry.Data,
+		UpdatedAt: result.UpdatedAt,
+	}
+	if err := f.write(fileRecord{Op: fileOpUpdate, Entry: &update}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (f *FileStore) Drop(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.write(fileRecord{Op: fileOpDrop, Tenant: TenantFrom(ctx)})
+}
+
+func (f *FileStore) DeleteBefore(ctx context.Context, cutoff time.Time, name string, exclude []string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := f.expired(TenantFrom(ctx), cutoff, name, exclude)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	record := fileRecord{Op: fileOpDelete}
+	for id := range ids {
+		record.IDs = append(record.IDs, id)
+	}
+	if err := f.write(record); err != nil {
+		return 0, err
+	}
+
+	return int64(len(ids)), nil
+}
+
+func (f *FileStore) DeleteIDs(ctx context.Context, ids []string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	found := f.owned(TenantFrom(ctx), ids)
+	if len(found) == 0 {
+		return 0, nil
+	}
+
+	record := fileRecord{Op: fileOpDelete}
+	for id := range found {
+		record.IDs = append(record.IDs, id)
+	}
+	if err := f.write(record); err != nil {
+		return 0, err
+	}
+
+	return int64(len(found)), nil
+}
+
+func (f *FileStore) SaveRetentionPolicy(ctx context.Context, policy RetentionPolicy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.write(fileRecord{Op: fileOpPolicy, Policy: &policy, Tenant: TenantFrom(ctx)})
+}
+
+func (f *FileStore) DeleteRetentionPolicy(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.policies[scopedName{TenantFrom(ctx), name}]; !ok {
+		return ErrNotFound
+	}
+
+	return f.write(fileRecord{Op: fileOpDeletePolicy, Name: name, Tenant: TenantFrom(ctx)})
+}
+
+func (f *FileStore) SaveAlertRule(ctx context.Context, rule AlertRule) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.write(fileRecord{Op: fileOpRule, Rule: &rule, Tenant: TenantFrom(ctx)})
+}
+
+func (f *FileStore) MarkAlertRuleFired(ctx context.Context, id string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rule, ok := f.rules[scopedName{TenantFrom(ctx), id}]
+	if !ok {
+		return ErrNotFound
+	}
+	rule.LastFiredAt = at
+
+	return f.write(fileRecord{Op: fileOpRule, Rule: &rule, Tenant: TenantFrom(ctx)})
+}
+
+func (f *FileStore) DeleteAlertRule(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.rules[scopedName{TenantFrom(ctx), id}]; !ok {
+		return ErrNotFound
+	}
+
+	return f.write(fileRecord{Op: fileOpDeleteRule, Name: id, Tenant: TenantFrom(ctx)})
+}
+
+// Close syncs and closes the journal.
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.file.Sync(); err != nil {
+		log.Println("Error syncing log file store", err)
+	}
+	return f.file.Close()
+}
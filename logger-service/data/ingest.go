@@ -0,0 +1,261 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrInvalidIngestConfig = errors.New("dedup_seconds must not be negative and sample rates must be between 0 and 1")
+
+// maxPendingEntries bounds the entries held back for deduplication. Beyond
+// it new entries are written straight away.
+const maxPendingEntries = 10000
+
+var (
+	ingestSampledOut = expvar.NewInt("log_ingest_sampled_out")
+	ingestCollapsed  = expvar.NewInt("log_ingest_collapsed")
+)
+
+type IngestConfig struct {
+	// DedupSeconds is the window in which identical entries (same name,
+	// data and level) are collapsed into one with a repeat count. Zero
+	// turns deduplication off.
+	DedupSeconds int `json:"dedup_seconds"`
+	// DebugSampleRate is the share of DEBUG entries kept, from 0 to 1, for
+	// names without a rate in DebugSampleRates.
+	DebugSampleRate  float64            `json:"debug_sample_rate"`
+	DebugSampleRates map[string]float64 `json:"debug_sample_rates,omitempty"`
+}
+
+func (c IngestConfig) valid() bool {
+	if c.DedupSeconds < 0 || c.DebugSampleRate < 0 || c.DebugSampleRate > 1 {
+		return false
+	}
+	for _, rate := range c.DebugSampleRates {
+		if rate < 0 || rate > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c IngestConfig) sampleRate(name string) float64 {
+	if rate, ok := c.DebugSampleRates[name]; ok {
+		return rate
+	}
+	return c.DebugSampleRate
+}
+
+type dedupKey struct {
+	tenant string
+	name   string
+	data   string
+	level  string
+}
+
+type pendingEntry struct {
+	entry   LogEntry
+	expires time.Time
+}
+
+// Ingest wraps a LogStore and thins out what is written through it: DEBUG
+// entries are sampled per name, and identical entries within the dedup
+// window are held back and written once, with Repeat counting them.
+// Held-back entries are written when their window ends or on Close.
+type Ingest struct {
+	LogStore
+
+	mu      sync.Mutex
+	config  IngestConfig
+	pending map[dedupKey]*pendingEntry
+	closed  bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewIngest starts applying config to writes to store. Call Close during
+// shutdown to write the entries still held back.
+func NewIngest(store LogStore, config IngestConfig) (*Ingest, error) {
+	if !config.valid() {
+		return nil, ErrInvalidIngestConfig
+	}
+
+	i := &Ingest{
+		LogStore: store,
+		config:   config,
+		pending:  make(map[dedupKey]*pendingEntry),
+		done:     make(chan struct{}),
+	}
+
+	i.wg.Add(1)
+	go i.run()
+
+	return i, nil
+}
+
+func (i *Ingest) Config() IngestConfig {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.config
+}
+
+// SetConfig replaces the config. Entries already held back are still
+// written when their window ends.
+func (i *Ingest) SetConfig(config IngestConfig) error {
+	if !config.valid() {
+		return ErrInvalidIngestConfig
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.config = config
+	return nil
+}
+
+// admit samples entry and, when deduplication is on, holds it back. It
+// returns true when entry must be written now.
+func (i *Ingest) admit(ctx context.Context, entry LogEntry) bool {
+	level := NormalizeLevel(entry.Level)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if level == "DEBUG" {
+		if rate := i.config.sampleRate(entry.Name); rate < 1 && rand.Float64() >= rate {
+			ingestSampledOut.Add(1)
+			return false
+		}
+	}
+
+	if i.config.DedupSeconds == 0 || i.closed {
+		return true
+	}
+
+	now := time.Now()
+	key := dedupKey{tenant: TenantFrom(ctx), name: entry.Name, data: entry.Data, level: level}
+	if p, ok := i.pending[key]; ok && now.Before(p.expires) {
+		p.entry.Repeat++
+		ingestCollapsed.Add(1)
+		return false
+	}
+	if len(i.pending) >= maxPendingEntries {
+		return true
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	entry.Tenant = key.tenant
+	entry.Repeat = 1
+	i.pending[key] = &pendingEntry{
+		entry:   entry,
+		expires: now.Add(time.Duration(i.config.DedupSeconds) * time.Second),
+	}
+	return false
+}
+
+func (i *Ingest) Insert(ctx context.Context, entry LogEntry) error {
+	if !i.admit(ctx, entry) {
+		return nil
+	}
+	return i.LogStore.Insert(ctx, entry)
+}
+
+// InsertMany reports entries sampled out or held back as written, like
+// Insert does.
+func (i *Ingest) InsertMany(ctx context.Context, entries []LogEntry) ([]error, error) {
+	kept := make([]LogEntry, 0, len(entries))
+	positions := make([]int, 0, len(entries))
+	for n, entry := range entries {
+		if i.admit(ctx, entry) {
+			kept = append(kept, entry)
+			positions = append(positions, n)
+		}
+	}
+
+	itemErrors := make([]error, len(entries))
+	if len(kept) == 0 {
+		return itemErrors, nil
+	}
+
+	keptErrors, err := i.LogStore.InsertMany(ctx, kept)
+	for n, itemErr := range keptErrors {
+		itemErrors[positions[n]] = itemErr
+	}
+
+	return itemErrors, err
+}
+
+// Close writes every entry still held back.
+func (i *Ingest) Close() {
+	i.mu.Lock()
+	if i.closed {
+		i.mu.Unlock()
+		return
+	}
+	i.closed = true
+	close(i.done)
+	i.mu.Unlock()
+
+	i.wg.Wait()
+}
+
+func (i *Ingest) run() {
+	defer i.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			i.flush(now)
+		case <-i.done:
+			i.flush(time.Time{})
+			return
+		}
+	}
+}
+
+// flush writes the held-back entries whose window ended before now, or all
+// of them when now is zero.
+func (i *Ingest) flush(now time.Time) {
+	var batch []LogEntry
+
+	i.mu.Lock()
+	for key, p := range i.pending {
+		if now.IsZero() || !now.Before(p.expires) {
+			if p.entry.Repeat == 1 {
+				p.entry.Repeat = 0
+			}
+			batch = append(batch, p.entry)
+			delete(i.pending, key)
+		}
+	}
+	i.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	sort.Slice(batch, func(a, b int) bool {
+		return batch[a].CreatedAt.Before(batch[b].CreatedAt)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for tenant, entries := range byTenant(batch) {
+		if _, err := i.LogStore.InsertMany(WithTenant(ctx, tenant), entries); err != nil {
+			log.Println("Error writing deduplicated log entries", err)
+		}
+	}
+}
@@ -0,0 +1,647 @@
+package data
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in process memory. Entries get Mongo-style
+// ObjectID hex ids so export cursors behave the same as with MongoStore, and
+// are partitioned by their Tenant field.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	entries  []*LogEntry
+	byID     map[string]*LogEntry
+	policies map[scopedName]RetentionPolicy
+	rules    map[scopedName]AlertRule
+}
+
+// scopedName keys retention policies and alert rules, which every tenant
+// keeps its own set of.
+type scopedName struct {
+	tenant string
+	name   string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		byID:     make(map[string]*LogEntry),
+		policies: make(map[scopedName]RetentionPolicy),
+		rules:    make(map[scopedName]AlertRule),
+	}
+}
+
+// add stores entry and returns the stored copy. Callers hold the write lock.
+func (m *MemoryStore) add(entry LogEntry) *LogEntry {
+	stored := entry
+	if stored.Tenant == "" {
+		stored.Tenant = DefaultTenant
+	}
+	if stored.ID == "" {
+		stored.ID = primitive.NewObjectID().Hex()
+	}
+	m.entries = append(m.entries, &stored)
+	m.byID[stored.ID] = &stored
+	return &stored
+}
+
+// lookup returns the stored entry with id if it belongs to tenant. Callers
+// hold a lock.
+func (m *MemoryStore) lookup(tenant, id string) (*LogEntry, bool) {
+	stored, ok := m.byID[id]
+	if !ok || stored.Tenant != tenant {
+		return nil, false
+	}
+	return stored, true
+}
+
+// prepareUpdate checks an Update of entry against the stored entry. Callers
+// hold m.mu.
+func (m *MemoryStore) prepareUpdate(tenant string, entry LogEntry) (*UpdateResult, error) {
+	stored, ok := m.lookup(tenant, entry.ID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !entry.UpdatedAt.IsZero() && !entry.UpdatedAt.Equal(stored.UpdatedAt) {
+		return nil, ErrConflict
+	}
+
+	return &UpdateResult{
+		ID:        entry.ID,
+		Modified:  stored.Name != entry.Name || stored.Data != entry.Data,
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+func (m *MemoryStore) update(entry LogEntry) bool {
+	stored, ok := m.byID[entry.ID]
+	if !ok {
+		return false
+	}
+	stored.Name = entry.Name
+	stored.Data = entry.Data
+	stored.UpdatedAt = entry.UpdatedAt
+	return true
+}
+
+func (m *MemoryStore) remove(ids map[string]bool) {
+	kept := m.entries[:0]
+	for _, entry := range m.entries {
+		if ids[entry.ID] {
+			delete(m.byID, entry.ID)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	for i := len(kept); i < len(m.entries); i++ {
+		m.entries[i] = nil
+	}
+	m.entries = kept
+}
+
+func (m *MemoryStore) drop(tenant string) {
+	ids := make(map[string]bool)
+	for _, entry := range m.entries {
+		if entry.Tenant == tenant {
+			ids[entry.ID] = true
+		}
+	}
+	m.remove(ids)
+}
+
+// seqTaken reports whether tenant has a chained entry with seq. Callers
+// hold a lock.
+func (m *MemoryStore) seqTaken(tenant string, seq int64) bool {
+	if seq == 0 {
+		return false
+	}
+	for _, entry := range m.entries {
+		if entry.Tenant == tenant && entry.Seq == seq {
+			return true
+		}
+	}
+	return false
+}
+
+// idTaken reports whether an entry with id is stored. Callers hold a lock.
+func (m *MemoryStore) idTaken(id string) bool {
+	_, ok := m.byID[id]
+	return id != "" && ok
+}
+
+func (m *MemoryStore) Insert(ctx context.Context, entry LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.idTaken(entry.ID) || m.seqTaken(TenantFrom(ctx), entry.Seq) {
+		return ErrConflict
+	}
+
+	stored := newEntry(entry, time.Now())
+	stored.ID = entry.ID
+	stored.Tenant = TenantFrom(ctx)
+	m.add(stored)
+	return nil
+}
+
+func (m *MemoryStore) InsertMany(ctx context.Context, entries []LogEntry) ([]error, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	itemErrors := make([]error, len(entries))
+	now := time.Now()
+	for i, entry := range entries {
+		if m.idTaken(entry.ID) || m.seqTaken(TenantFrom(ctx), entry.Seq) {
+			itemErrors[i] = ErrConflict
+			continue
+		}
+		stored := newEntry(entry, now)
+		stored.ID = entry.ID
+		stored.Tenant = TenantFrom(ctx)
+		m.add(stored)
+	}
+	return itemErrors, nil
+}
+
+func (m *MemoryStore) GetOne(ctx context.Context, id string) (*LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.lookup(TenantFrom(ctx), id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *entry
+	return &found, nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, entry LogEntry) (*UpdateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, err := m.prepareUpdate(TenantFrom(ctx), entry)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.UpdatedAt = result.UpdatedAt
+	m.update(entry)
+	return result, nil
+}
+
+func (m *MemoryStore) Drop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.drop(TenantFrom(ctx))
+	return nil
+}
+
+// matching copies the entries of tenant that match q, in insertion order.
+func (m *MemoryStore) matching(tenant string, q LogQuery) []*LogEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*LogEntry
+	for _, entry := range m.entries {
+		if entry.Tenant == tenant && q.matches(entry) {
+			copied := *entry
+			found = append(found, &copied)
+		}
+	}
+	return found
+}
+
+func (m *MemoryStore) Find(ctx context.Context, q LogQuery) (*LogPage, error) {
+	q.normalize()
+
+	entries := m.matching(TenantFrom(ctx), q)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+
+	return paginate(entries, q), nil
+}
+
+// Search approximates Mongo's $text semantics: an entry matches when it
+// contains any plain term, every quoted phrase and no negated term, and is
+// scored by how many terms and phrases it contains.
+func (m *MemoryStore) Search(ctx context.Context, text, phrase string, q LogQuery) (*LogPage, error) {
+	q.normalize()
+
+	if phrase != "" {
+		text += ` "` + phrase + `"`
+	}
+	terms, phrases, negated := parseSearch(text)
+
+	var found []*LogEntry
+	for _, entry := range m.matching(TenantFrom(ctx), q) {
+		haystack := strings.ToLower(entry.Name + " " + entry.Data)
+
+		excluded := false
+		for _, term := range negated {
+			if strings.Contains(haystack, term) {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+
+		score := 0.0
+		missingPhrase := false
+		for _, p := range phrases {
+			if !strings.Contains(haystack, p) {
+				missingPhrase = true
+				break
+			}
+			score++
+		}
+		if missingPhrase {
+			continue
+		}
+
+		termHits := 0
+		for _, term := range terms {
+			if strings.Contains(haystack, term) {
+				termHits++
+			}
+		}
+		if len(terms) > 0 && termHits == 0 {
+			continue
+		}
+
+		entry.Score = score + float64(termHits)
+		found = append(found, entry)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Score != found[j].Score {
+			return found[i].Score > found[j].Score
+		}
+		return found[i].CreatedAt.After(found[j].CreatedAt)
+	})
+
+	return paginate(found, q), nil
+}
+
+// parseSearch splits a $text style query into lower-cased plain terms,
+// quoted phrases and terms negated with a leading minus.
+func parseSearch(text string) (terms, phrases, negated []string) {
+	text = strings.ToLower(text)
+	for {
+		start := strings.Index(text, `"`)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start+1:], `"`)
+		if end < 0 {
+			break
+		}
+		if p := strings.TrimSpace(text[start+1 : start+1+end]); p != "" {
+			phrases = append(phrases, p)
+		}
+		text = text[:start] + " " + text[start+end+2:]
+	}
+
+	for _, word := range strings.Fields(text) {
+		if strings.HasPrefix(word, "-") {
+			if word = strings.TrimPrefix(word, "-"); word != "" {
+				negated = append(negated, word)
+			}
+			continue
+		}
+		terms = append(terms, word)
+	}
+
+	return terms, phrases, negated
+}
+
+func paginate(entries []*LogEntry, q LogQuery) *LogPage {
+	page := &LogPage{
+		Entries:  []*LogEntry{},
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Total:    int64(len(entries)),
+	}
+
+	start := (q.Page - 1) * q.PageSize
+	if start >= len(entries) {
+		return page
+	}
+	end := start + q.PageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	page.Entries = entries[start:end]
+
+	return page
+}
+
+func (m *MemoryStore) Stats(ctx context.Context, q StatsQuery) (*LogStats, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+
+	type seriesKey struct{ time, name, level string }
+	series := make(map[seriesKey]int64)
+	names := make(map[string]*NameStats)
+
+	stats := q.newStats()
+	var total, errs int64
+
+	for _, entry := range m.matching(TenantFrom(ctx), q.LogQuery) {
+		series[seriesKey{bucketTime(entry.CreatedAt, q.Bucket), entry.Name, entry.Level}]++
+
+		n, ok := names[entry.Name]
+		if !ok {
+			n = &NameStats{Name: entry.Name}
+			names[entry.Name] = n
+		}
+		n.Count++
+		total++
+		if isErrorLevel(entry.Level) {
+			n.Errors++
+			errs++
+		}
+	}
+
+	for key, count := range series {
+		stats.Series = append(stats.Series, StatsBucket{Time: key.time, Name: key.name, Level: key.level, Count: count})
+	}
+	sort.Slice(stats.Series, func(i, j int) bool {
+		a, b := stats.Series[i], stats.Series[j]
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Level < b.Level
+	})
+
+	for _, n := range names {
+		n.ErrorRate = float64(n.Errors) / float64(n.Count)
+		stats.TopNames = append(stats.TopNames, *n)
+	}
+	sort.Slice(stats.TopNames, func(i, j int) bool {
+		a, b := stats.TopNames[i], stats.TopNames[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	if len(stats.TopNames) > q.Top {
+		stats.TopNames = stats.TopNames[:q.Top]
+	}
+
+	stats.setTotals(total, errs)
+
+	return stats, nil
+}
+
+func (m *MemoryStore) Export(ctx context.Context, q LogQuery, after string, limit int64, fn func(*LogEntry) error) (string, error) {
+	if after != "" {
+		if _, err := primitive.ObjectIDFromHex(after); err != nil {
+			return "", ErrInvalidCursor
+		}
+	}
+
+	entries := m.matching(TenantFrom(ctx), q)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	last := after
+	var written int64
+	for _, entry := range entries {
+		if after != "" && entry.ID <= after {
+			continue
+		}
+		if limit > 0 && written >= limit {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return last, err
+		}
+		if err := fn(entry); err != nil {
+			return last, err
+		}
+		last = entry.ID
+		written++
+	}
+
+	return last, nil
+}
+
+// chained copies the audit-chained entries of tenant in Seq order.
+func (m *MemoryStore) chained(tenant string) []*LogEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*LogEntry
+	for _, entry := range m.entries {
+		if entry.Tenant == tenant && entry.Seq > 0 {
+			copied := *entry
+			found = append(found, &copied)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Seq < found[j].Seq
+	})
+	return found
+}
+
+func (m *MemoryStore) LastChained(ctx context.Context) (*LogEntry, error) {
+	entries := m.chained(TenantFrom(ctx))
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return entries[len(entries)-1], nil
+}
+
+func (m *MemoryStore) Chain(ctx context.Context, from int64, fn func(*LogEntry) error) error {
+	for _, entry := range m.chained(TenantFrom(ctx)) {
+		if entry.Seq < from {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expired returns the ids of tenant DeleteBefore would remove. Callers hold
+// a lock.
+func (m *MemoryStore) expired(tenant string, cutoff time.Time, name string, exclude []string) map[string]bool {
+	excluded := make(map[string]bool, len(exclude))
+	for _, n := range exclude {
+		excluded[n] = true
+	}
+
+	ids := make(map[string]bool)
+	for _, entry := range m.entries {
+		if entry.Tenant != tenant || !entry.retainedSince().Before(cutoff) {
+			continue
+		}
+		if name != "" && entry.Name != name {
+			continue
+		}
+		if name == "" && excluded[entry.Name] {
+			continue
+		}
+		ids[entry.ID] = true
+	}
+	return ids
+}
+
+func (m *MemoryStore) DeleteBefore(ctx context.Context, cutoff time.Time, name string, exclude []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.expired(TenantFrom(ctx), cutoff, name, exclude)
+	m.remove(ids)
+	return int64(len(ids)), nil
+}
+
+// owned returns the ids that exist and belong to tenant. Callers hold a
+// lock.
+func (m *MemoryStore) owned(tenant string, ids []string) map[string]bool {
+	found := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := m.lookup(tenant, id); ok {
+			found[id] = true
+		}
+	}
+	return found
+}
+
+func (m *MemoryStore) DeleteIDs(ctx context.Context, ids []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := m.owned(TenantFrom(ctx), ids)
+	m.remove(found)
+	return int64(len(found)), nil
+}
+
+// Tenants lists DefaultTenant and every tenant with entries.
+func (m *MemoryStore) Tenants(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := map[string]bool{DefaultTenant: true}
+	tenants := []string{DefaultTenant}
+	for _, entry := range m.entries {
+		if !seen[entry.Tenant] {
+			seen[entry.Tenant] = true
+			tenants = append(tenants, entry.Tenant)
+		}
+	}
+	sort.Strings(tenants[1:])
+
+	return tenants, nil
+}
+
+func (m *MemoryStore) RetentionPolicies(ctx context.Context) ([]*RetentionPolicy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenant := TenantFrom(ctx)
+
+	var policies []*RetentionPolicy
+	for key, policy := range m.policies {
+		if key.tenant != tenant {
+			continue
+		}
+		p := policy
+		policies = append(policies, &p)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+	return policies, nil
+}
+
+func (m *MemoryStore) SaveRetentionPolicy(ctx context.Context, policy RetentionPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.policies[scopedName{TenantFrom(ctx), policy.Name}] = policy
+	return nil
+}
+
+func (m *MemoryStore) DeleteRetentionPolicy(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := scopedName{TenantFrom(ctx), name}
+	if _, ok := m.policies[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.policies, key)
+	return nil
+}
+
+func (m *MemoryStore) AlertRules(ctx context.Context) ([]*AlertRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenant := TenantFrom(ctx)
+
+	var rules []*AlertRule
+	for key, rule := range m.rules {
+		if key.tenant != tenant {
+			continue
+		}
+		r := rule
+		rules = append(rules, &r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+func (m *MemoryStore) SaveAlertRule(ctx context.Context, rule AlertRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules[scopedName{TenantFrom(ctx), rule.ID}] = rule
+	return nil
+}
+
+func (m *MemoryStore) MarkAlertRuleFired(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := scopedName{TenantFrom(ctx), id}
+	rule, ok := m.rules[key]
+	if !ok {
+		return ErrNotFound
+	}
+	rule.LastFiredAt = at
+	m.rules[key] = rule
+	return nil
+}
+
+func (m *MemoryStore) DeleteAlertRule(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := scopedName{TenantFrom(ctx), id}
+	if _, ok := m.rules[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.rules, key)
+	return nil
+}
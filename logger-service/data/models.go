@@ -1,145 +1,91 @@
 package data
 
 import (
-	"context"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
+	"strings"
 	"time"
 )
 
-var client *mongo.Client
-
-func New(mongoClient *mongo.Client) Models {
-	client = mongoClient
-
+func New(store LogStore) Models {
 	return Models{
-		LogEntry: LogEntry{},
+		Logs:      store,
+		Retention: Retention{store: store},
+		Entries:   Entries{store: store},
 	}
 }
 
 type Models struct {
-	LogEntry LogEntry
+	Logs      LogStore
+	Retention Retention
+	Entries   Entries
 }
 
 type LogEntry struct {
 	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
 	Name      string    `bson:"name" json:"name"`
 	Data      string    `bson:"data" json:"data"`
+	Level     string    `bson:"level" json:"level"`
+	Host      string    `bson:"host,omitempty" json:"host,omitempty"`
+	Facility  string    `bson:"facility,omitempty" json:"facility,omitempty"`
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	Seq       int64     `bson:"seq,omitempty" json:"seq,omitempty"`
+	PrevHash  string    `bson:"prev_hash,omitempty" json:"prev_hash,omitempty"`
+	Hash      string    `bson:"hash,omitempty" json:"hash,omitempty"`
+	Score     float64   `bson:"score,omitempty" json:"score,omitempty"`
+	// Repeat counts the identical entries this one stands for when
+	// ingestion deduplicated them.
+	Repeat int64 `bson:"repeat,omitempty" json:"repeat,omitempty"`
+	// RestoredAt is set on entries reimported from the archive, which
+	// archive runs leave alone and retention ages from their restore.
+	RestoredAt time.Time `bson:"restored_at,omitempty" json:"restored_at,omitempty"`
+	// Tenant is only kept by the stores that partition in memory; Mongo
+	// stores each tenant in its own collection.
+	Tenant string `bson:"-" json:"-"`
 }
 
-func (l *LogEntry) Insert(entry LogEntry) error {
-	collection := client.Database("logs").Collection("logs")
-	_, err := collection.InsertOne(context.TODO(), LogEntry{
-		Name:      entry.Name,
-		Data:      entry.Data,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	})
-	if err != nil {
-		log.Println("Error inserting log entry", err)
-		return err
-	}
-	return nil
-}
-
-func (l *LogEntry) All() ([]*LogEntry, error) {
-
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	collection := client.Database("logs").Collection("logs")
-
-	opts := options.Find()
-	opts.SetSort(bson.D{{"created_at", -1}})
-
-	cursor, err := collection.Find(context.TODO(), bson.D{}, opts)
-	if err != nil {
-		log.Println("Error finding log entries", err)
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var logs []*LogEntry
-
-	for cursor.Next(ctx) {
-		var item LogEntry
-		err := cursor.Decode(&item)
-		if err != nil {
-			log.Println("Error decoding log entry", err)
-			return nil, err
-		} else {
-			logs = append(logs, &item)
-		}
-	}
-
-	return logs, nil
-}
-
-func (l *LogEntry) GetOne(id string) (*LogEntry, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	collection := client.Database("logs").Collection("logs")
-
-	docID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		log.Println("Error converting id to object id", err)
-		return nil, err
+// retainedSince is when retention starts counting the age of the entry.
+func (e *LogEntry) retainedSince() time.Time {
+	if !e.RestoredAt.IsZero() {
+		return e.RestoredAt
 	}
-
-	var log LogEntry
-	err = collection.FindOne(ctx, bson.D{{"_id", docID}}).Decode(&log)
-
-	return &log, nil
+	return e.CreatedAt
 }
 
-func (l *LogEntry) DropCollection(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+// DefaultLevel is stored for entries written without a level, matching the
+// log.INFO routing key the broker uses by default.
+const DefaultLevel = "INFO"
 
-	collection := client.Database("logs").Collection("logs")
-
-	if err := collection.Drop(ctx); err != nil {
-		log.Println("Error dropping collection", err)
-		return err
+// NormalizeLevel upper-cases level and falls back to DefaultLevel.
+func NormalizeLevel(level string) string {
+	if level == "" {
+		return DefaultLevel
 	}
-
-	return nil
+	return strings.ToUpper(level)
 }
 
-func (l *LogEntry) Update() (*mongo.UpdateResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	collection := client.Database("logs").Collection("logs")
-
-	docID, err := primitive.ObjectIDFromHex(l.ID)
-	if err != nil {
-		log.Println("Error converting id to object id", err)
-		return nil, err
+// newEntry returns the document a store persists for entry: only the
+// caller-supplied fields, a normalized level and the write timestamps.
+// CreatedAt is kept when already set, so buffered entries keep the time they
+// were received.
+func newEntry(entry LogEntry, now time.Time) LogEntry {
+	createdAt := entry.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = now
 	}
 
-	result, err := collection.UpdateOne(
-		ctx,
-		bson.D{{"_id", docID}},
-		bson.D{
-			{"$set", bson.D{
-
-				{"name", l.Name},
-				{"data", l.Data},
-				{"updated_at", time.Now()},
-			}},
-		},
-	)
-	if err != nil {
-		log.Println("Error updating log entry", err)
-		return nil, err
+	return LogEntry{
+		Name:       entry.Name,
+		Data:       entry.Data,
+		Level:      NormalizeLevel(entry.Level),
+		Host:       entry.Host,
+		Facility:   entry.Facility,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+		Seq:        entry.Seq,
+		PrevHash:   entry.PrevHash,
+		Hash:       entry.Hash,
+		Repeat:     entry.Repeat,
+		RestoredAt: entry.RestoredAt,
+		Tenant:     entry.Tenant,
 	}
-
-	return result, nil
 }
@@ -0,0 +1,777 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bucketFormats truncate created_at with $dateToString, which unlike
+// $dateTrunc is available on the Mongo 4.2 images we deploy.
+var bucketFormats = map[string]string{
+	"minute": "%Y-%m-%dT%H:%M:00Z",
+	"hour":   "%Y-%m-%dT%H:00:00Z",
+	"day":    "%Y-%m-%dT00:00:00Z",
+}
+
+// MongoStore keeps log entries in the logs.logs collection, retention
+// policies in logs.retention_policies and alert rules in logs.alert_rules.
+// Tenants other than DefaultTenant get their own logs.logs_<tenant>
+// collection, indexed on first use, and their own
+// retention_policies_<tenant> and alert_rules_<tenant>.
+type MongoStore struct {
+	client  *mongo.Client
+	indexed sync.Map
+}
+
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	return &MongoStore{client: client}
+}
+
+// logs returns the collection of the tenant ctx is scoped to.
+func (m *MongoStore) logs(ctx context.Context) *mongo.Collection {
+	collection := m.client.Database("logs").Collection(logsCollection(TenantFrom(ctx)))
+	if _, done := m.indexed.LoadOrStore(collection.Name(), true); !done {
+		if err := createLogIndexes(ctx, collection); err != nil {
+			m.indexed.Delete(collection.Name())
+		}
+	}
+	return collection
+}
+
+func logsCollection(tenant string) string {
+	return tenantCollection("logs", tenant)
+}
+
+// policies and alertRules return the collections of the ctx tenant, named
+// like its logs collection.
+func (m *MongoStore) policies(ctx context.Context) *mongo.Collection {
+	return m.client.Database("logs").Collection(tenantCollection("retention_policies", TenantFrom(ctx)))
+}
+
+func (m *MongoStore) alertRules(ctx context.Context) *mongo.Collection {
+	return m.client.Database("logs").Collection(tenantCollection("alert_rules", TenantFrom(ctx)))
+}
+
+func tenantCollection(base, tenant string) string {
+	if tenant == DefaultTenant {
+		return base
+	}
+	return base + "_" + tenant
+}
+
+// EnsureIndexes creates the indexes the logger's queries rely on in the
+// collection of every tenant. It is safe to call on every start.
+func (m *MongoStore) EnsureIndexes(ctx context.Context) error {
+	tenants, err := m.Tenants(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, tenant := range tenants {
+		// logs creates the indexes on first use.
+		m.logs(WithTenant(ctx, tenant))
+		if _, ok := m.indexed.Load(logsCollection(tenant)); !ok {
+			return fmt.Errorf("indexing logs of tenant %s failed", tenant)
+		}
+	}
+
+	return nil
+}
+
+// Tenants lists DefaultTenant and every tenant with a collection.
+func (m *MongoStore) Tenants(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	names, err := m.client.Database("logs").ListCollectionNames(ctx, bson.D{{"name", bson.D{{"$regex", "^logs_"}}}})
+	if err != nil {
+		log.Println("Error listing tenant collections", err)
+		return nil, err
+	}
+
+	tenants := []string{DefaultTenant}
+	for _, name := range names {
+		if tenant := strings.TrimPrefix(name, "logs_"); ValidTenant(tenant) && tenant != DefaultTenant {
+			tenants = append(tenants, tenant)
+		}
+	}
+	sort.Strings(tenants[1:])
+
+	return tenants, nil
+}
+
+func createLogIndexes(ctx context.Context, collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// seq_1 did not enforce uniqueness; seq_unique replaces it. Dropping it
+	// fails once it is gone, which is fine.
+	collection.Indexes().DropOne(ctx, "seq_1")
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"created_at", 1}}},
+		{Keys: bson.D{{"name", 1}, {"created_at", 1}}},
+		{Keys: bson.D{{"level", 1}, {"created_at", 1}}},
+		{
+			// Two writers extending the same audit chain cannot both
+			// take a Seq.
+			Keys: bson.D{{"seq", 1}},
+			Options: options.Index().SetName("seq_unique").SetUnique(true).
+				SetPartialFilterExpression(bson.D{{"seq", bson.D{{"$gt", 0}}}}),
+		},
+		{
+			Keys:    bson.D{{"name", "text"}, {"data", "text"}},
+			Options: options.Index().SetName("name_data_text"),
+		},
+	})
+	if err != nil {
+		log.Println("Error creating log indexes on", collection.Name(), err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *MongoStore) Insert(ctx context.Context, entry LogEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	doc, err := mongoEntry(entry, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = m.logs(ctx).InsertOne(ctx, doc)
+	if (entry.ID != "" || entry.Seq > 0) && mongo.IsDuplicateKeyError(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		log.Println("Error inserting log entry", err)
+		return err
+	}
+	return nil
+}
+
+func (m *MongoStore) InsertMany(ctx context.Context, entries []LogEntry) ([]error, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	itemErrors := make([]error, len(entries))
+	if len(entries) == 0 {
+		return itemErrors, nil
+	}
+
+	now := time.Now()
+	docs := make([]interface{}, 0, len(entries))
+	// positions maps each document back to its entry.
+	positions := make([]int, 0, len(entries))
+	for i, entry := range entries {
+		doc, err := mongoEntry(entry, now)
+		if err != nil {
+			itemErrors[i] = err
+			continue
+		}
+		docs = append(docs, doc)
+		positions = append(positions, i)
+	}
+	if len(docs) == 0 {
+		return itemErrors, nil
+	}
+
+	_, err := m.logs(ctx).InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			log.Println("Error inserting log entries", err)
+			return nil, err
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			if writeErr.Index < 0 || writeErr.Index >= len(positions) {
+				continue
+			}
+			i := positions[writeErr.Index]
+			if (entries[i].ID != "" || entries[i].Seq > 0) && mongo.IsDuplicateKeyError(writeErr) {
+				itemErrors[i] = ErrConflict
+			} else {
+				itemErrors[i] = errors.New(writeErr.Message)
+			}
+		}
+	}
+
+	return itemErrors, nil
+}
+
+// mongoEntry returns the document stored for entry. An entry with an ID
+// keeps it as its object id.
+func mongoEntry(entry LogEntry, now time.Time) (interface{}, error) {
+	doc := newEntry(entry, now)
+	if entry.ID == "" {
+		return doc, nil
+	}
+
+	docID, err := primitive.ObjectIDFromHex(entry.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log entry id %q", entry.ID)
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.D
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	return append(bson.D{{"_id", docID}}, fields...), nil
+}
+
+func (m *MongoStore) GetOne(ctx context.Context, id string) (*LogEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// No entry can have an id that is not an object id.
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	var entry LogEntry
+	err = m.logs(ctx).FindOne(ctx, bson.D{{"_id", docID}}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Println("Error finding log entry", err)
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (m *MongoStore) Update(ctx context.Context, entry LogEntry) (*UpdateResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	docID, err := primitive.ObjectIDFromHex(entry.ID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	filter := bson.D{{"_id", docID}}
+	if !entry.UpdatedAt.IsZero() {
+		filter = append(filter, bson.E{"updated_at", entry.UpdatedAt})
+	}
+
+	now := time.Now()
+	var before LogEntry
+	err = m.logs(ctx).FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.D{
+			{"$set", bson.D{
+				{"name", entry.Name},
+				{"data", entry.Data},
+				{"updated_at", now},
+			}},
+		},
+	).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if entry.UpdatedAt.IsZero() {
+			return nil, ErrNotFound
+		}
+		// Tell a missing entry from one updated in the meantime.
+		if _, err := m.GetOne(ctx, entry.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrConflict
+	}
+	if err != nil {
+		log.Println("Error updating log entry", err)
+		return nil, err
+	}
+
+	return &UpdateResult{
+		ID:        entry.ID,
+		Modified:  before.Name != entry.Name || before.Data != entry.Data,
+		UpdatedAt: now,
+	}, nil
+}
+
+func (m *MongoStore) Drop(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	collection := m.logs(ctx)
+	if err := collection.Drop(ctx); err != nil {
+		log.Println("Error dropping collection", err)
+		return err
+	}
+	m.indexed.Delete(collection.Name())
+
+	return nil
+}
+
+func mongoFilter(q LogQuery) bson.D {
+	filter := bson.D{}
+	if q.Name != "" {
+		filter = append(filter, bson.E{Key: "name", Value: q.Name})
+	}
+	if q.Level != "" {
+		filter = append(filter, bson.E{Key: "level", Value: strings.ToUpper(q.Level)})
+	}
+
+	createdAt := bson.D{}
+	if !q.From.IsZero() {
+		createdAt = append(createdAt, bson.E{Key: "$gte", Value: q.From})
+	}
+	if !q.To.IsZero() {
+		createdAt = append(createdAt, bson.E{Key: "$lt", Value: q.To})
+	}
+	if len(createdAt) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: createdAt})
+	}
+	if q.SkipRestored {
+		filter = append(filter, bson.E{Key: "restored_at", Value: bson.D{{"$exists", false}}})
+	}
+
+	return filter
+}
+
+func (m *MongoStore) Find(ctx context.Context, q LogQuery) (*LogPage, error) {
+	q.normalize()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{"created_at", -1}})
+
+	return m.findPage(ctx, mongoFilter(q), q, opts)
+}
+
+func (m *MongoStore) Search(ctx context.Context, text, phrase string, q LogQuery) (*LogPage, error) {
+	q.normalize()
+
+	if phrase != "" {
+		text += ` "` + phrase + `"`
+	}
+
+	filter := append(mongoFilter(q), bson.E{Key: "$text", Value: bson.D{{"$search", text}}})
+
+	opts := options.Find()
+	opts.SetProjection(bson.D{{"score", bson.D{{"$meta", "textScore"}}}})
+	opts.SetSort(bson.D{{"score", bson.D{{"$meta", "textScore"}}}, {"created_at", -1}})
+
+	return m.findPage(ctx, filter, q, opts)
+}
+
+func (m *MongoStore) findPage(ctx context.Context, filter bson.D, q LogQuery, opts *options.FindOptions) (*LogPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	collection := m.logs(ctx)
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Println("Error counting log entries", err)
+		return nil, err
+	}
+
+	opts.SetSkip(int64((q.Page - 1) * q.PageSize))
+	opts.SetLimit(int64(q.PageSize))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println("Error finding log entries", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*LogEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		log.Println("Error decoding log entries", err)
+		return nil, err
+	}
+
+	return &LogPage{
+		Entries:  entries,
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Total:    total,
+	}, nil
+}
+
+// Stats runs a single $facet pipeline for the series, top names and totals.
+func (m *MongoStore) Stats(ctx context.Context, q StatsQuery) (*LogStats, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	format := bucketFormats[q.Bucket]
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	isError := bson.D{{"$cond", bson.A{
+		bson.D{{"$in", bson.A{"$level", ErrorLevels}}}, 1, 0,
+	}}}
+	errorRate := bson.D{{"$cond", bson.A{
+		bson.D{{"$eq", bson.A{"$count", 0}}},
+		0,
+		bson.D{{"$divide", bson.A{"$errors", "$count"}}},
+	}}}
+
+	pipeline := bson.A{
+		bson.D{{"$match", mongoFilter(q.LogQuery)}},
+		bson.D{{"$facet", bson.D{
+			{"series", bson.A{
+				bson.D{{"$group", bson.D{
+					{"_id", bson.D{
+						{"time", bson.D{{"$dateToString", bson.D{{"format", format}, {"date", "$created_at"}}}}},
+						{"name", "$name"},
+						{"level", "$level"},
+					}},
+					{"count", bson.D{{"$sum", 1}}},
+				}}},
+				bson.D{{"$project", bson.D{
+					{"_id", 0},
+					{"time", "$_id.time"},
+					{"name", "$_id.name"},
+					{"level", "$_id.level"},
+					{"count", 1},
+				}}},
+				bson.D{{"$sort", bson.D{{"time", 1}, {"name", 1}, {"level", 1}}}},
+			}},
+			{"top", bson.A{
+				bson.D{{"$group", bson.D{
+					{"_id", "$name"},
+					{"count", bson.D{{"$sum", 1}}},
+					{"errors", bson.D{{"$sum", isError}}},
+				}}},
+				bson.D{{"$sort", bson.D{{"count", -1}, {"_id", 1}}}},
+				bson.D{{"$limit", q.Top}},
+				bson.D{{"$project", bson.D{
+					{"_id", 0},
+					{"name", "$_id"},
+					{"count", 1},
+					{"errors", 1},
+					{"error_rate", errorRate},
+				}}},
+			}},
+			{"totals", bson.A{
+				bson.D{{"$group", bson.D{
+					{"_id", nil},
+					{"count", bson.D{{"$sum", 1}}},
+					{"errors", bson.D{{"$sum", isError}}},
+				}}},
+			}},
+		}}},
+	}
+
+	cursor, err := m.logs(ctx).Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Println("Error aggregating log stats", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Series []StatsBucket `bson:"series"`
+		Top    []NameStats   `bson:"top"`
+		Totals []struct {
+			Count  int64 `bson:"count"`
+			Errors int64 `bson:"errors"`
+		} `bson:"totals"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		log.Println("Error decoding log stats", err)
+		return nil, err
+	}
+
+	stats := q.newStats()
+	if len(results) == 0 {
+		return stats, nil
+	}
+
+	if results[0].Series != nil {
+		stats.Series = results[0].Series
+	}
+	if results[0].Top != nil {
+		stats.TopNames = results[0].Top
+	}
+	if len(results[0].Totals) > 0 {
+		stats.setTotals(results[0].Totals[0].Count, results[0].Totals[0].Errors)
+	}
+
+	return stats, nil
+}
+
+func (m *MongoStore) Export(ctx context.Context, q LogQuery, after string, limit int64, fn func(*LogEntry) error) (string, error) {
+	filter := mongoFilter(q)
+	if after != "" {
+		afterID, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			return "", ErrInvalidCursor
+		}
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{"$gt", afterID}}})
+	}
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{"_id", 1}})
+	opts.SetBatchSize(500)
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := m.logs(ctx).Find(ctx, filter, opts)
+	if err != nil {
+		log.Println("Error exporting log entries", err)
+		return "", err
+	}
+	defer cursor.Close(ctx)
+
+	last := after
+	for cursor.Next(ctx) {
+		var item LogEntry
+		if err := cursor.Decode(&item); err != nil {
+			log.Println("Error decoding log entry", err)
+			return last, err
+		}
+		if err := fn(&item); err != nil {
+			return last, err
+		}
+		last = item.ID
+	}
+
+	return last, cursor.Err()
+}
+
+func (m *MongoStore) LastChained(ctx context.Context) (*LogEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().SetSort(bson.D{{"seq", -1}})
+
+	var entry LogEntry
+	err := m.logs(ctx).FindOne(ctx, bson.D{{"seq", bson.D{{"$gt", 0}}}}, opts).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Println("Error finding last chained log entry", err)
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (m *MongoStore) Chain(ctx context.Context, from int64, fn func(*LogEntry) error) error {
+	opts := options.Find()
+	opts.SetSort(bson.D{{"seq", 1}})
+	opts.SetBatchSize(500)
+
+	cursor, err := m.logs(ctx).Find(ctx, bson.D{{"seq", bson.D{{"$gte", max(from, 1)}}}}, opts)
+	if err != nil {
+		log.Println("Error reading log chain", err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item LogEntry
+		if err := cursor.Decode(&item); err != nil {
+			log.Println("Error decoding log entry", err)
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (m *MongoStore) DeleteBefore(ctx context.Context, cutoff time.Time, name string, exclude []string) (int64, error) {
+	// Restored entries age from their restore.
+	filter := bson.D{{"$or", bson.A{
+		bson.D{{"created_at", bson.D{{"$lt", cutoff}}}, {"restored_at", bson.D{{"$exists", false}}}},
+		bson.D{{"restored_at", bson.D{{"$lt", cutoff}}}},
+	}}}
+	if name != "" {
+		filter = append(filter, bson.E{Key: "name", Value: name})
+	} else if len(exclude) > 0 {
+		filter = append(filter, bson.E{Key: "name", Value: bson.D{{"$nin", exclude}}})
+	}
+
+	result, err := m.logs(ctx).DeleteMany(ctx, filter)
+	if err != nil {
+		log.Println("Error deleting old log entries", err)
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+func (m *MongoStore) DeleteIDs(ctx context.Context, ids []string) (int64, error) {
+	docIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		// No entry can have an id that is not an object id, so there is
+		// nothing to remove for it.
+		docID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			continue
+		}
+		docIDs = append(docIDs, docID)
+	}
+	if len(docIDs) == 0 {
+		return 0, nil
+	}
+
+	result, err := m.logs(ctx).DeleteMany(ctx, bson.D{{"_id", bson.D{{"$in", docIDs}}}})
+	if err != nil {
+		log.Println("Error deleting log entries", err)
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+func (m *MongoStore) RetentionPolicies(ctx context.Context) ([]*RetentionPolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{"_id", 1}})
+
+	cursor, err := m.policies(ctx).Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println("Error finding retention policies", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var policies []*RetentionPolicy
+	if err := cursor.All(ctx, &policies); err != nil {
+		log.Println("Error decoding retention policies", err)
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+func (m *MongoStore) SaveRetentionPolicy(ctx context.Context, policy RetentionPolicy) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	_, err := m.policies(ctx).ReplaceOne(
+		ctx,
+		bson.D{{"_id", policy.Name}},
+		policy,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Println("Error saving retention policy", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *MongoStore) DeleteRetentionPolicy(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	result, err := m.policies(ctx).DeleteOne(ctx, bson.D{{"_id", name}})
+	if err != nil {
+		log.Println("Error deleting retention policy", err)
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *MongoStore) AlertRules(ctx context.Context) ([]*AlertRule, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{"_id", 1}})
+
+	cursor, err := m.alertRules(ctx).Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println("Error finding alert rules", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rules []*AlertRule
+	if err := cursor.All(ctx, &rules); err != nil {
+		log.Println("Error decoding alert rules", err)
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (m *MongoStore) SaveAlertRule(ctx context.Context, rule AlertRule) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	_, err := m.alertRules(ctx).ReplaceOne(
+		ctx,
+		bson.D{{"_id", rule.ID}},
+		rule,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Println("Error saving alert rule", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *MongoStore) MarkAlertRuleFired(ctx context.Context, id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	result, err := m.alertRules(ctx).UpdateOne(
+		ctx,
+		bson.D{{"_id", id}},
+		bson.D{{"$set", bson.D{{"last_fired_at", at}}}},
+	)
+	if err != nil {
+		log.Println("Error recording alert", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *MongoStore) DeleteAlertRule(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	result, err := m.alertRules(ctx).DeleteOne(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		log.Println("Error deleting alert rule", err)
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
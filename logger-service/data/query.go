@@ -0,0 +1,70 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid export cursor")
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// LogQuery holds the filters and pagination shared by every log query.
+// Zero values mean "no filter".
+type LogQuery struct {
+	Name     string
+	Level    string
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+	// SkipRestored leaves out entries reimported from the archive.
+	SkipRestored bool
+}
+
+// LogPage is one page of query results. Total counts every matching entry,
+// not just the ones on this page.
+type LogPage struct {
+	Entries  []*LogEntry `json:"entries"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+	Total    int64       `json:"total"`
+}
+
+func (q *LogQuery) normalize() {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = defaultPageSize
+	}
+	if q.PageSize > maxPageSize {
+		q.PageSize = maxPageSize
+	}
+}
+
+// matches applies the filters of q to a single entry, for stores that
+// cannot push them down to a database.
+func (q LogQuery) matches(entry *LogEntry) bool {
+	if q.Name != "" && entry.Name != q.Name {
+		return false
+	}
+	if q.Level != "" && entry.Level != strings.ToUpper(q.Level) {
+		return false
+	}
+	if !q.From.IsZero() && entry.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !entry.CreatedAt.Before(q.To) {
+		return false
+	}
+	if q.SkipRestored && !entry.RestoredAt.IsZero() {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,277 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrRedactionRejected is returned for an Update that matches a drop rule.
+var ErrRedactionRejected = errors.New("rejected by redaction rules")
+
+// ErrRedactHashKey is returned when hash mode is used without a key. An
+// unkeyed hash of an email or card number is easily reversed.
+var ErrRedactHashKey = errors.New("hash redaction needs a hash key")
+
+type RedactionMode string
+
+const (
+	// RedactMask replaces the match with [REDACTED:<rule>].
+	RedactMask RedactionMode = "mask"
+	// RedactHash replaces the match with a keyed hash, so equal values can
+	// still be correlated across entries.
+	RedactHash RedactionMode = "hash"
+	// RedactDrop discards the whole entry.
+	RedactDrop RedactionMode = "drop"
+)
+
+var (
+	redactedMatches = expvar.NewInt("log_redacted_matches")
+	redactedDropped = expvar.NewInt("log_redacted_dropped_entries")
+)
+
+// RedactionRule is a custom detector. When Pattern has a group named
+// secret only that group is redacted, e.g. `token=(?P<secret>\w+)`.
+type RedactionRule struct {
+	Name    string        `json:"name"`
+	Pattern string        `json:"pattern"`
+	Mode    RedactionMode `json:"mode,omitempty"`
+}
+
+type RedactionConfig struct {
+	// Detectors names the built-in detectors to run; see BuiltinDetectors.
+	Detectors []string
+	Rules     []RedactionRule
+	// Mode applies to the built-in detectors and to rules without a mode.
+	Mode    RedactionMode
+	HashKey string
+}
+
+type builtinDetector struct {
+	pattern string
+	valid   func(match string) bool
+}
+
+var builtinDetectors = map[string]builtinDetector{
+	"email":    {pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`},
+	"bearer":   {pattern: `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`},
+	"password": {pattern: `(?i)\\?"(?:password|passwd|pwd|secret)\\?"\s*:\s*\\?"(?P<secret>[^"\\]*)`},
+	"card":     {pattern: `\b(?:\d[ -]?){12,18}\d\b`, valid: luhn},
+}
+
+// BuiltinDetectors lists the detector names NewRedactor accepts.
+func BuiltinDetectors() []string {
+	return []string{"email", "bearer", "password", "card"}
+}
+
+type detector struct {
+	name   string
+	re     *regexp.Regexp
+	secret int
+	mode   RedactionMode
+	valid  func(match string) bool
+}
+
+// Redactor wraps a LogStore and scrubs the name and data of every entry
+// before it is written.
+type Redactor struct {
+	LogStore
+	detectors []detector
+	hashKey   []byte
+}
+
+func NewRedactor(store LogStore, config RedactionConfig) (*Redactor, error) {
+	if config.Mode == "" {
+		config.Mode = RedactMask
+	}
+	if !validRedactionMode(config.Mode) {
+		return nil, fmt.Errorf("unknown redaction mode %q", config.Mode)
+	}
+
+	r := &Redactor{LogStore: store, hashKey: []byte(config.HashKey)}
+
+	for _, name := range config.Detectors {
+		builtin, ok := builtinDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q", name)
+		}
+		r.add(name, regexp.MustCompile(builtin.pattern), config.Mode, builtin.valid)
+	}
+
+	for _, rule := range config.Rules {
+		mode := rule.Mode
+		if mode == "" {
+			mode = config.Mode
+		}
+		if rule.Name == "" || !validRedactionMode(mode) {
+			return nil, fmt.Errorf("invalid redaction rule %q", rule.Name)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %w", rule.Name, err)
+		}
+		r.add(rule.Name, re, mode, nil)
+	}
+
+	for _, d := range r.detectors {
+		if d.mode == RedactHash && len(r.hashKey) == 0 {
+			return nil, ErrRedactHashKey
+		}
+	}
+
+	return r, nil
+}
+
+func validRedactionMode(mode RedactionMode) bool {
+	return mode == RedactMask || mode == RedactHash || mode == RedactDrop
+}
+
+func (r *Redactor) add(name string, re *regexp.Regexp, mode RedactionMode, valid func(string) bool) {
+	r.detectors = append(r.detectors, detector{
+		name:   name,
+		re:     re,
+		secret: re.SubexpIndex("secret"),
+		mode:   mode,
+		valid:  valid,
+	})
+}
+
+// Redact returns entry with its name and data scrubbed, and false when a
+// drop rule matched and the entry must not be stored.
+func (r *Redactor) Redact(entry LogEntry) (LogEntry, bool) {
+	for _, d := range r.detectors {
+		if d.mode == RedactDrop && (d.found(entry.Name) || d.found(entry.Data)) {
+			redactedDropped.Add(1)
+			return entry, false
+		}
+	}
+
+	for _, d := range r.detectors {
+		if d.mode != RedactDrop {
+			entry.Name = r.replace(d, entry.Name)
+			entry.Data = r.replace(d, entry.Data)
+		}
+	}
+
+	return entry, true
+}
+
+func (d detector) found(s string) bool {
+	if d.valid == nil {
+		return d.re.MatchString(s)
+	}
+	for _, match := range d.re.FindAllString(s, -1) {
+		if d.valid(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// replace redacts every match of d in s, or only its secret group when the
+// pattern has one.
+func (r *Redactor) replace(d detector, s string) string {
+	matches := d.re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if d.secret > 0 {
+			start, end = m[2*d.secret], m[2*d.secret+1]
+		}
+		if start < 0 || start == end || (d.valid != nil && !d.valid(s[m[0]:m[1]])) {
+			continue
+		}
+
+		b.WriteString(s[last:start])
+		b.WriteString(r.replacement(d, s[start:end]))
+		last = end
+		redactedMatches.Add(1)
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+func (r *Redactor) replacement(d detector, value string) string {
+	if d.mode == RedactHash {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		return "[" + d.name + ":" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+	}
+	return "[REDACTED:" + d.name + "]"
+}
+
+func (r *Redactor) Insert(ctx context.Context, entry LogEntry) error {
+	entry, keep := r.Redact(entry)
+	if !keep {
+		return nil
+	}
+	return r.LogStore.Insert(ctx, entry)
+}
+
+// InsertMany reports dropped entries as written, like Insert does.
+func (r *Redactor) InsertMany(ctx context.Context, entries []LogEntry) ([]error, error) {
+	kept := make([]LogEntry, 0, len(entries))
+	positions := make([]int, 0, len(entries))
+	for i, entry := range entries {
+		if entry, keep := r.Redact(entry); keep {
+			kept = append(kept, entry)
+			positions = append(positions, i)
+		}
+	}
+
+	itemErrors := make([]error, len(entries))
+	if len(kept) == 0 {
+		return itemErrors, nil
+	}
+
+	keptErrors, err := r.LogStore.InsertMany(ctx, kept)
+	for i, itemErr := range keptErrors {
+		itemErrors[positions[i]] = itemErr
+	}
+
+	return itemErrors, err
+}
+
+// Update redacts the new name and data too. An update matching a drop rule
+// is refused rather than leaving the old content in place silently.
+func (r *Redactor) Update(ctx context.Context, entry LogEntry) (*UpdateResult, error) {
+	entry, keep := r.Redact(entry)
+	if !keep {
+		return nil, fmt.Errorf("update of %s %w", entry.ID, ErrRedactionRejected)
+	}
+	return r.LogStore.Update(ctx, entry)
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum, which keeps
+// the card detector off ids and timestamps.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if n%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r, err := NewRedactor(NewMemoryStore(), RedactionConfig{
+		Detectors: BuiltinDetectors(),
+		Rules: []RedactionRule{
+			{Name: "token", Pattern: `token=(?P<secret>\w+)`},
+			{Name: "ssn", Pattern: `\d{3}-\d{2}-\d{4}`, Mode: RedactDrop},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"email", "mail bob@example.com now", "mail [REDACTED:email] now"},
+		{"bearer keeps the scheme", "Authorization: Bearer abc.def-123", "Authorization: Bearer [REDACTED:bearer]"},
+		{"password field", `{"user":"a","password":"hunter2"}`, `{"user":"a","password":"[REDACTED:password]"}`},
+		{"escaped password field", `{\"pwd\": \"s3cret\"}`, `{\"pwd\": \"[REDACTED:password]\"}`},
+		{"card passing luhn", "paid with 4111 1111 1111 1111", "paid with [REDACTED:card]"},
+		{"digits failing luhn", "order 4111111111111112", "order 4111111111111112"},
+		{"custom rule secret group", "url?token=xyz789&x=1", "url?token=[REDACTED:token]&x=1"},
+		{"nothing to redact", "all clear", "all clear"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, keep := r.Redact(LogEntry{Name: "svc", Data: tt.data})
+			if !keep {
+				t.Fatalf("Redact(%q) dropped the entry", tt.data)
+			}
+			if got.Data != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.data, got.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactDrop(t *testing.T) {
+	store := NewMemoryStore()
+	r, err := NewRedactor(store, RedactionConfig{
+		Rules: []RedactionRule{{Name: "ssn", Pattern: `\d{3}-\d{2}-\d{4}`, Mode: RedactDrop}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	itemErrors, err := r.InsertMany(ctx, []LogEntry{
+		{Name: "a", Data: "ssn 123-45-6789"},
+		{Name: "b", Data: "fine"},
+	})
+	if err != nil || itemErrors[0] != nil || itemErrors[1] != nil {
+		t.Fatalf("InsertMany = %v, %v", itemErrors, err)
+	}
+
+	page, err := store.Find(ctx, LogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Entries[0].Name != "b" {
+		t.Errorf("stored %d entries, want only b", page.Total)
+	}
+
+	_, err = r.Update(ctx, LogEntry{ID: page.Entries[0].ID, Name: "b", Data: "now 123-45-6789"})
+	if !errors.Is(err, ErrRedactionRejected) {
+		t.Errorf("Update matching a drop rule returned %v, want ErrRedactionRejected", err)
+	}
+}
+
+func TestRedactHash(t *testing.T) {
+	config := RedactionConfig{Detectors: []string{"email"}, Mode: RedactHash, HashKey: "k1"}
+	r, err := NewRedactor(NewMemoryStore(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := r.Redact(LogEntry{Data: "a@example.com"})
+	second, _ := r.Redact(LogEntry{Data: "from a@example.com"})
+	if !strings.HasPrefix(first.Data, "[email:") || !strings.HasSuffix(second.Data, first.Data) {
+		t.Errorf("equal values hashed to %q and %q", first.Data, second.Data)
+	}
+
+	config.HashKey = "k2"
+	other, _ := NewRedactor(NewMemoryStore(), config)
+	if rekeyed, _ := other.Redact(LogEntry{Data: "a@example.com"}); rekeyed.Data == first.Data {
+		t.Errorf("hash does not depend on the key: %q", rekeyed.Data)
+	}
+
+	config.HashKey = ""
+	if _, err := NewRedactor(NewMemoryStore(), config); !errors.Is(err, ErrRedactHashKey) {
+		t.Errorf("NewRedactor without a hash key returned %v, want ErrRedactHashKey", err)
+	}
+}
+
+func TestNewRedactorInvalid(t *testing.T) {
+	tests := []RedactionConfig{
+		{Mode: "scramble"},
+		{Detectors: []string{"phone"}},
+		{Rules: []RedactionRule{{Pattern: `x`}}},
+		{Rules: []RedactionRule{{Name: "bad", Pattern: `(`}}},
+		{Rules: []RedactionRule{{Name: "bad", Pattern: `x`, Mode: "scramble"}}},
+	}
+
+	for _, config := range tests {
+		if _, err := NewRedactor(NewMemoryStore(), config); err == nil {
+			t.Errorf("NewRedactor(%+v) succeeded, want an error", config)
+		}
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := map[string]bool{
+		"4111111111111111":    true,
+		"4111-1111-1111-1111": true,
+		"4111111111111112":    false,
+		"0000000000000":       true,
+		"000000000000":        false,
+	}
+
+	for s, want := range tests {
+		if got := luhn(s); got != want {
+			t.Errorf("luhn(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
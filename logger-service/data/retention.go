@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// GlobalPolicy is the policy name that applies to every log name without a
+// policy of its own.
+const GlobalPolicy = "*"
+
+var ErrInvalidRetention = errors.New("retention days must be greater than zero")
+
+type RetentionPolicy struct {
+	Name        string    `bson:"_id" json:"name"`
+	Days        int       `bson:"days" json:"days"`
+	LastRunAt   time.Time `bson:"last_run_at,omitempty" json:"last_run_at,omitempty"`
+	LastRemoved int64     `bson:"last_removed" json:"last_removed"`
+	Removed     int64     `bson:"removed" json:"removed"`
+	UpdatedAt   time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Retention manages retention policies and purges expired entries from the
+// store. Every tenant has its own policies, which only ever remove its own
+// entries.
+type Retention struct {
+	store LogStore
+	// DefaultDays, when set, is the global policy EnforceAll gives tenants
+	// that have not configured one.
+	DefaultDays int
+}
+
+func (r *Retention) All(ctx context.Context) ([]*RetentionPolicy, error) {
+	return r.store.RetentionPolicies(ctx)
+}
+
+func (r *Retention) find(ctx context.Context, name string) (*RetentionPolicy, error) {
+	policies, err := r.store.RetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		if policy.Name == name {
+			return policy, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Upsert creates or changes the policy for policy.Name, keeping the removal
+// counters of an existing policy.
+func (r *Retention) Upsert(ctx context.Context, policy RetentionPolicy) error {
+	if policy.Days <= 0 {
+		return ErrInvalidRetention
+	}
+	if policy.Name == "" {
+		policy.Name = GlobalPolicy
+	}
+
+	existing, err := r.find(ctx, policy.Name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if existing != nil {
+		existing.Days = policy.Days
+		policy = *existing
+	}
+	policy.UpdatedAt = time.Now()
+
+	return r.store.SaveRetentionPolicy(ctx, policy)
+}
+
+// SeedGlobal creates the global policy of the ctx tenant with the given
+// number of days unless one has already been configured through the admin
+// endpoint.
+func (r *Retention) SeedGlobal(ctx context.Context, days int) error {
+	if days <= 0 {
+		return ErrInvalidRetention
+	}
+
+	_, err := r.find(ctx, GlobalPolicy)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return r.store.SaveRetentionPolicy(ctx, RetentionPolicy{
+		Name:      GlobalPolicy,
+		Days:      days,
+		UpdatedAt: time.Now(),
+	})
+}
+
+func (r *Retention) Delete(ctx context.Context, name string) error {
+	return r.store.DeleteRetentionPolicy(ctx, name)
+}
+
+// EnforceAll enforces the policies of every tenant, seeding a global policy
+// of DefaultDays in tenants that have none. It returns the policies with
+// LastRemoved set for this run by tenant.
+func (r *Retention) EnforceAll(ctx context.Context) (map[string][]*RetentionPolicy, error) {
+	tenants, err := r.store.Tenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enforced := make(map[string][]*RetentionPolicy, len(tenants))
+	for _, tenant := range tenants {
+		tenantCtx := WithTenant(ctx, tenant)
+
+		if r.DefaultDays > 0 {
+			if err := r.SeedGlobal(tenantCtx, r.DefaultDays); err != nil {
+				log.Println("Error seeding global retention policy for tenant", tenant, err)
+				return enforced, err
+			}
+		}
+
+		policies, err := r.Enforce(tenantCtx)
+		if err != nil {
+			return enforced, err
+		}
+		enforced[tenant] = policies
+	}
+
+	return enforced, nil
+}
+
+// Enforce deletes the log entries of the ctx tenant that are older than its
+// policies allow. Named policies cover entries with that name; the global
+// policy covers every other name. It returns the policies with LastRemoved
+// set for this run.
+func (r *Retention) Enforce(ctx context.Context) ([]*RetentionPolicy, error) {
+	policies, err := r.store.RetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	var named []string
+	for _, policy := range policies {
+		if policy.Name != GlobalPolicy {
+			named = append(named, policy.Name)
+		}
+	}
+
+	now := time.Now()
+	for _, policy := range policies {
+		cutoff := now.AddDate(0, 0, -policy.Days)
+
+		var removed int64
+		if policy.Name == GlobalPolicy {
+			removed, err = r.store.DeleteBefore(ctx, cutoff, "", named)
+		} else {
+			removed, err = r.store.DeleteBefore(ctx, cutoff, policy.Name, nil)
+		}
+		if err != nil {
+			log.Println("Error enforcing retention policy", policy.Name, "for tenant", TenantFrom(ctx), err)
+			return nil, err
+		}
+
+		policy.LastRunAt = now
+		policy.LastRemoved = removed
+		policy.Removed += removed
+
+		if err := r.store.SaveRetentionPolicy(ctx, *policy); err != nil {
+			log.Println("Error recording retention run", policy.Name, err)
+			return nil, err
+		}
+	}
+
+	return policies, nil
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorLevels are the levels counted as errors in error rates.
+var ErrorLevels = []string{"ERROR", "FATAL", "CRITICAL"}
+
+var ErrInvalidBucket = errors.New("bucket must be one of minute, hour or day")
+
+type StatsQuery struct {
+	LogQuery
+	// Bucket is minute, hour or day. Defaults to hour.
+	Bucket string
+	// Top is how many names to return in TopNames. Defaults to 10.
+	Top int
+}
+
+type LogStats struct {
+	Bucket    string        `json:"bucket"`
+	From      time.Time     `json:"from"`
+	To        time.Time     `json:"to"`
+	Total     int64         `json:"total"`
+	Errors    int64         `json:"errors"`
+	ErrorRate float64       `json:"error_rate"`
+	Series    []StatsBucket `json:"series"`
+	TopNames  []NameStats   `json:"top_names"`
+}
+
+type StatsBucket struct {
+	Time  string `bson:"time" json:"time"`
+	Name  string `bson:"name" json:"name"`
+	Level string `bson:"level" json:"level"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+type NameStats struct {
+	Name      string  `bson:"name" json:"name"`
+	Count     int64   `bson:"count" json:"count"`
+	Errors    int64   `bson:"errors" json:"errors"`
+	ErrorRate float64 `bson:"error_rate" json:"error_rate"`
+}
+
+// normalize fills in the defaults. Without a time range the stats cover the
+// last 24 hours.
+func (q *StatsQuery) normalize() error {
+	if q.Bucket == "" {
+		q.Bucket = "hour"
+	}
+	if _, ok := bucketFormats[q.Bucket]; !ok {
+		return ErrInvalidBucket
+	}
+	if q.Top <= 0 {
+		q.Top = 10
+	}
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-24 * time.Hour)
+	}
+
+	return nil
+}
+
+func (q StatsQuery) newStats() *LogStats {
+	return &LogStats{
+		Bucket:   q.Bucket,
+		From:     q.From,
+		To:       q.To,
+		Series:   []StatsBucket{},
+		TopNames: []NameStats{},
+	}
+}
+
+func (s *LogStats) setTotals(total, errors int64) {
+	s.Total = total
+	s.Errors = errors
+	if total > 0 {
+		s.ErrorRate = float64(errors) / float64(total)
+	}
+}
+
+// bucketTime formats t the same way the Mongo pipeline's $dateToString does.
+func bucketTime(t time.Time, bucket string) string {
+	t = t.UTC()
+	switch bucket {
+	case "minute":
+		t = t.Truncate(time.Minute)
+	case "day":
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		t = t.Truncate(time.Hour)
+	}
+	return t.Format("2006-01-02T15:04:05Z")
+}
+
+func isErrorLevel(level string) bool {
+	for _, l := range ErrorLevels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
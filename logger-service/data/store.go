@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned by Update when the entry was updated since the
+// caller read it.
+var ErrConflict = errors.New("log entry was updated by someone else")
+
+// UpdateResult reports the outcome of an Update.
+type UpdateResult struct {
+	ID string `json:"id"`
+	// Modified is false when the entry already had the given name and data.
+	Modified  bool      `json:"modified"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LogStore is the storage behind every logger transport. MongoStore is the
+// production backend; MemoryStore and FileStore let the logger run without
+// Mongo in development and tests.
+type LogStore interface {
+	// Insert stores a single entry. An entry with an ID keeps it. An entry
+	// whose ID is already stored, or an audit-chained entry whose Seq is
+	// already taken in its tenant, is refused with ErrConflict.
+	Insert(ctx context.Context, entry LogEntry) error
+	// InsertMany stores entries in one call, like Insert does one. The
+	// returned slice has one slot per entry and holds the error for that
+	// entry, or nil if it was stored.
+	InsertMany(ctx context.Context, entries []LogEntry) ([]error, error)
+	// GetOne returns the entry with id, or ErrNotFound.
+	GetOne(ctx context.Context, id string) (*LogEntry, error)
+	// Update rewrites the name and data of the entry with entry.ID, or
+	// returns ErrNotFound. When entry.UpdatedAt is set it must match the
+	// stored entry, otherwise Update returns ErrConflict.
+	Update(ctx context.Context, entry LogEntry) (*UpdateResult, error)
+	// Drop removes every entry.
+	Drop(ctx context.Context) error
+
+	// Find returns a page of entries matching q, newest first.
+	Find(ctx context.Context, q LogQuery) (*LogPage, error)
+	// Search runs a full-text query over name and data, ranked by
+	// relevance. text uses Mongo's $text syntax, so quoted words match as a
+	// phrase and a leading minus excludes a term; phrase, when set, is added
+	// as a quoted phrase.
+	Search(ctx context.Context, text, phrase string, q LogQuery) (*LogPage, error)
+	// Stats aggregates the entries matching q into per-bucket counts by
+	// name and level, the most frequent names and error rates.
+	Stats(ctx context.Context, q StatsQuery) (*LogStats, error)
+	// Export streams every entry matching q in id order to fn without
+	// buffering the result set. Pagination fields are ignored; after, when
+	// set, is the id of the last entry already exported and limit, when
+	// positive, caps the number of entries. It returns the id of the last
+	// entry passed to fn.
+	Export(ctx context.Context, q LogQuery, after string, limit int64, fn func(*LogEntry) error) (string, error)
+
+	// LastChained returns the audit-chained entry with the highest Seq, or
+	// ErrNotFound if there is none.
+	LastChained(ctx context.Context) (*LogEntry, error)
+	// Chain streams the audit-chained entries with Seq >= from to fn in Seq
+	// order.
+	Chain(ctx context.Context, from int64, fn func(*LogEntry) error) error
+
+	// Tenants lists the tenants with stored entries, DefaultTenant first.
+	// Every other method works on the tenant its context is scoped to; see
+	// WithTenant.
+	Tenants(ctx context.Context) ([]string, error)
+
+	// DeleteBefore removes entries created before cutoff, or for entries
+	// restored from the archive, restored before cutoff. With a name it
+	// only removes entries with that name; otherwise it removes entries
+	// whose name is not in exclude.
+	DeleteBefore(ctx context.Context, cutoff time.Time, name string, exclude []string) (int64, error)
+	// DeleteIDs removes the entries with the given ids and returns how many
+	// there were. Ids of no entry, valid or not, are skipped.
+	DeleteIDs(ctx context.Context, ids []string) (int64, error)
+
+	RetentionPolicies(ctx context.Context) ([]*RetentionPolicy, error)
+	// SaveRetentionPolicy creates or replaces the policy with policy.Name.
+	SaveRetentionPolicy(ctx context.Context, policy RetentionPolicy) error
+	DeleteRetentionPolicy(ctx context.Context, name string) error
+
+	AlertRules(ctx context.Context) ([]*AlertRule, error)
+	// SaveAlertRule creates or replaces the rule with rule.ID.
+	SaveAlertRule(ctx context.Context, rule AlertRule) error
+	// MarkAlertRuleFired sets only the LastFiredAt of the rule with id, or
+	// returns ErrNotFound.
+	MarkAlertRuleFired(ctx context.Context, id string, at time.Time) error
+	DeleteAlertRule(ctx context.Context, id string) error
+}
+
+var (
+	_ LogStore = (*MongoStore)(nil)
+	_ LogStore = (*MemoryStore)(nil)
+	_ LogStore = (*FileStore)(nil)
+	_ LogStore = (*BufferedStore)(nil)
+	_ LogStore = (*Alerter)(nil)
+	_ LogStore = (*Redactor)(nil)
+	_ LogStore = (*Auditor)(nil)
+	_ LogStore = (*Ingest)(nil)
+)
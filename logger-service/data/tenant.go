@@ -0,0 +1,35 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// DefaultTenant owns entries written without a tenant id. Its entries stay
+// in the original logs.logs collection.
+const DefaultTenant = "default"
+
+var ErrInvalidTenant = errors.New("tenant id must be 1 to 48 lowercase letters, digits, - or _")
+
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,47}$`)
+
+type tenantKey struct{}
+
+func ValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// WithTenant scopes every LogStore call made with the returned context to
+// tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant ctx is scoped to, or DefaultTenant.
+func TenantFrom(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}